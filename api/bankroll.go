@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+	"greekkeepers.io/backend/responses"
+)
+
+func (c *SharedController) ListBankrolls(context *gin.Context) {
+	var bankrolls []db.Bankroll
+
+	c.Db.Find(&bankrolls)
+
+	response, _ := json.Marshal(bankrolls)
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
+func (c *SharedController) GetBankroll(context *gin.Context) {
+	var coin db.Coin
+	if err := c.Db.Where("id = ?", context.Param("coinID")).First(&coin).Error; err != nil {
+		slog.Error("Coin not found", "coinId", context.Param("coinID"))
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Coin not found"})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	bankroll, err := c.Db.GetBankroll(coin.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Bankroll is not tracked for the coin"})
+		context.IndentedJSON(http.StatusNotFound,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+	if err != nil {
+		slog.Error("Error getting bankroll", "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Error getting bankroll"})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	response, _ := json.Marshal(bankroll)
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
+func (c *SharedController) TopUpBankroll(context *gin.Context) {
+	var topUp requests.BankrollTopUp
+
+	if err := context.BindJSON(&topUp); err != nil {
+		slog.Error("Parsing bankroll top up error", "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: err.Error()})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	bankroll, err := c.Db.TopUpBankroll(topUp.CoinID, topUp.Amount)
+	if err != nil {
+		slog.Error("Error topping up bankroll", "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Error topping up bankroll"})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+	slog.Info("Bankroll topped up", "coinId", topUp.CoinID, "amount", topUp.Amount, "bankroll", bankroll.Amount)
+
+	response, _ := json.Marshal(bankroll)
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
+func (c *SharedController) SetBankrollExposure(context *gin.Context) {
+	var exposure requests.BankrollExposure
+
+	if err := context.BindJSON(&exposure); err != nil {
+		slog.Error("Parsing bankroll exposure error", "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: err.Error()})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+	if exposure.ExposureFraction.IsNegative() || exposure.ExposureFraction.GreaterThan(decimal.NewFromInt(1)) {
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Exposure fraction must be between 0 and 1"})
+		context.IndentedJSON(http.StatusBadRequest,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	bankroll, err := c.Db.SetExposureFraction(exposure.CoinID, exposure.ExposureFraction)
+	if err != nil {
+		slog.Error("Error setting bankroll exposure", "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Error setting bankroll exposure"})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	response, _ := json.Marshal(bankroll)
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
+func BankrollEndpoints(sCtrl *SharedController, router *gin.Engine) {
+	router.GET("/bankroll", AuthMiddleware(), sCtrl.AdminMiddleware(), sCtrl.ListBankrolls)
+	router.GET("/bankroll/:coinID", AuthMiddleware(), sCtrl.AdminMiddleware(), sCtrl.GetBankroll)
+	router.POST("/bankroll/topup", AuthMiddleware(), sCtrl.AdminMiddleware(), sCtrl.TopUpBankroll)
+	router.PUT("/bankroll/exposure", AuthMiddleware(), sCtrl.AdminMiddleware(), sCtrl.SetBankrollExposure)
+}
@@ -2,10 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/engine"
+	"greekkeepers.io/backend/games"
 	"greekkeepers.io/backend/responses"
 )
 
@@ -24,6 +29,8 @@ func (c *SharedController) GetBets(context *gin.Context) {
                 Bets.bet_info,
                 Bets.state,
                 Bets.uuid,
+                Bets.nonce,
+                Bets.steps,
                 Bets.game_id,
                 Bets.user_id,
                 Users.username,
@@ -55,6 +62,8 @@ func (c *SharedController) GetBets(context *gin.Context) {
                 Bets.bet_info,
                 Bets.state,
                 Bets.uuid,
+                Bets.nonce,
+                Bets.steps,
                 Bets.game_id,
                 Bets.user_id,
                 Users.username,
@@ -107,6 +116,8 @@ func (c *SharedController) GetUserBets(context *gin.Context) {
                 Bets.bet_info,
                 Bets.state,
                 Bets.uuid,
+                Bets.nonce,
+                Bets.steps,
                 Bets.game_id,
                 Bets.user_id,
                 Users.username,
@@ -125,8 +136,126 @@ func (c *SharedController) GetUserBets(context *gin.Context) {
 
 }
 
+// VerifyBet replays a bet from its revealed seeds and compares the result with the stored one.
+func (c *SharedController) VerifyBet(context *gin.Context) {
+	betID, err := strconv.ParseUint(context.Param("betID"), 10, 32)
+	if err != nil {
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: err.Error()})
+		context.IndentedJSON(http.StatusBadRequest,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	var bet db.Bet
+	if err := c.Db.Preload("Game").Where("id = ?", betID).First(&bet).Error; err != nil {
+		slog.Error("Bet not found", "betId", betID, "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Bet not found"})
+		context.IndentedJSON(http.StatusNotFound,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	if bet.Game.Name == games.CrashName {
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: engine.ErrCrashBet.Error()})
+		context.IndentedJSON(http.StatusBadRequest,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	steps, err := engine.ParseSteps(bet.Steps)
+	if err != nil {
+		message := "Error parsing bet steps"
+		if errors.Is(err, engine.ErrNoSteps) {
+			message = "Bet was placed before steps were recorded and can't be verified"
+		}
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: message})
+		context.IndentedJSON(http.StatusBadRequest,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	userSeedIds := []uint{}
+	serverSeedIds := []uint{}
+	for _, step := range steps {
+		userSeedIds = append(userSeedIds, step.UserSeedID)
+		serverSeedIds = append(serverSeedIds, step.ServerSeedID)
+	}
+
+	var userSeeds []db.UserSeed
+	var serverSeeds []db.ServerSeed
+	if err := c.Db.Where("user_id = ? AND id IN ?", bet.UserID, userSeedIds).Find(&userSeeds).Error; err != nil {
+		slog.Error("Error getting user seeds", "betId", betID, "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Error getting seeds"})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+	if err := c.Db.Where("user_id = ? AND id IN ?", bet.UserID, serverSeedIds).Find(&serverSeeds).Error; err != nil {
+		slog.Error("Error getting server seeds", "betId", betID, "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Error getting seeds"})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	userSeedsById := make(map[uint]string)
+	for _, seed := range userSeeds {
+		userSeedsById[seed.ID] = seed.UserSeed
+	}
+	serverSeedsById := make(map[uint]string)
+	for _, seed := range serverSeeds {
+		if !seed.Revealed {
+			var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Server seed of the bet is not revealed yet"})
+			context.IndentedJSON(http.StatusForbidden,
+				responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+			return
+		}
+		serverSeedsById[seed.ID] = seed.ServerSeed
+	}
+
+	result, err := engine.ReplaySteps(bet.Game.Name, engine.VersionParams(c.Db, bet.Game), steps, func(step db.BetStep) (string, string, error) {
+		userSeed, ok := userSeedsById[step.UserSeedID]
+		if !ok {
+			return "", "", errors.New("User seed of the bet not found")
+		}
+		serverSeed, ok := serverSeedsById[step.ServerSeedID]
+		if !ok {
+			return "", "", errors.New("Server seed of the bet not found")
+		}
+		return userSeed, serverSeed, nil
+	})
+	if err != nil {
+		slog.Error("Error replaying bet", "betId", betID, "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: err.Error()})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	outcomesMatch, profitsMatch, err := engine.ResultMatches(bet, result)
+	if err != nil {
+		slog.Error("Error comparing bet results", "betId", betID, "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Error comparing bet results"})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	response, _ := json.Marshal(responses.BetVerification{
+		BetID:         bet.ID,
+		Verified:      outcomesMatch && profitsMatch,
+		OutcomesMatch: outcomesMatch,
+		ProfitsMatch:  profitsMatch,
+		Outcomes:      result.Outcomes,
+		Profits:       result.Profits,
+		TotalProfit:   result.TotalProfit,
+	})
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
 func BetsEndpoints(sCtrl *SharedController, router *gin.Engine) {
 	router.GET("/bets/list/:gameName", sCtrl.GetBets)
 	router.GET("/bets/list", sCtrl.GetBets)
 	router.GET("/bets/user/:userID", sCtrl.GetUserBets)
+	router.GET("/bets/:betID/verify", sCtrl.VerifyBet)
 }
@@ -8,9 +8,11 @@ import (
 )
 
 type SharedController struct {
-	Db                     *db.DB
-	Env                    *config.Env
-	Manager                *communications.Manager
-	StatelessEngineChannel chan engine.Bet
-	StatefulEngineChannel  chan engine.Bet
+	Db       *db.DB
+	Env      *config.Env
+	Manager  *communications.Manager
+	Engine   *engine.Pool
+	AutoBets *engine.AutoBets
+	// Crash is nil when the Crash game isn't in the games table
+	Crash *engine.CrashEngine
 }
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/responses"
+)
+
+// CrashRoundsLimit is the amount of finished rounds returned by GET /crash/rounds.
+const CrashRoundsLimit = 100
+
+// ListCrashChains returns the commitments of the hash chains the rounds are played with.
+func (c *SharedController) ListCrashChains(context *gin.Context) {
+	var chains []db.CrashChain
+
+	c.Db.Order("id DESC").Find(&chains)
+
+	response, _ := json.Marshal(chains)
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
+// ListCrashRounds returns the latest finished rounds with their hashes,
+// the hash of a round hashes to the hash of the round before it and the first one to the commitment.
+func (c *SharedController) ListCrashRounds(context *gin.Context) {
+	var rounds []db.CrashRound
+
+	c.Db.Where("finished").Order("id DESC").Limit(CrashRoundsLimit).Find(&rounds)
+
+	response, _ := json.Marshal(rounds)
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
+func CrashEndpoints(sCtrl *SharedController, router *gin.Engine) {
+	router.GET("/crash/chains", sCtrl.ListCrashChains)
+	router.GET("/crash/rounds", sCtrl.ListCrashRounds)
+}
@@ -1,9 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -21,6 +26,25 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize: 1024,
 }
 
+// openConnections tracks websockets, http.Server.Shutdown doesn't wait for hijacked connections.
+var openConnections sync.WaitGroup
+
+// WaitConnections waits for every websocket to disconnect or for ctx to be done.
+func WaitConnections(ctx context.Context) error {
+	closed := make(chan struct{})
+	go func() {
+		openConnections.Wait()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func WebsocketsReader(conn *websocket.Conn, channel chan requests.WSrequest) {
 	for {
 		// Read message from client
@@ -40,10 +64,12 @@ func WebsocketsHandler(c *gin.Context, sCtrl *SharedController) {
 		slog.Error("Upgrade failed", "err", err)
 		return
 	}
+	openConnections.Add(1)
+	defer openConnections.Done()
 	readerChannel := make(chan requests.WSrequest)
 	go WebsocketsReader(conn, readerChannel)
 
-	managerFeed := make(chan communications.Broadcast)
+	managerFeed := make(chan communications.Broadcast, communications.FeedSize)
 	UUID := uuid.New()
 	response := responses.WSresponse{
 		Id:   0,
@@ -51,13 +77,13 @@ func WebsocketsHandler(c *gin.Context, sCtrl *SharedController) {
 	}
 	conn.WriteJSON(&response)
 
-	communications.ManagerPub.ManagerReceiver <- communications.ManagerEvent{
+	communications.ManagerPub.Post(communications.ManagerEvent{
 		Type: communications.SubscribeFeed,
 		Body: communications.ManagerEventSubscribeFeed{
 			Id:   UUID.String(),
 			Feed: managerFeed,
 		},
-	}
+	})
 
 	slog.Info("Connected", "conn", conn)
 
@@ -65,17 +91,34 @@ func WebsocketsHandler(c *gin.Context, sCtrl *SharedController) {
 
 	defer func() {
 		conn.Close()
-		communications.ManagerPub.ManagerReceiver <- communications.ManagerEvent{
+		communications.ManagerPub.Post(communications.ManagerEvent{
 			Type: communications.UnsubscribeFeed,
 			Body: communications.ManagerEventUnsubscribeFeed{
 				Id: UUID.String(),
 			},
-		}
+		})
 	}()
 	for {
 		message := requests.WSrequest{}
 		select {
-		case response := <-managerFeed:
+		case response, ok := <-managerFeed:
+			if !ok {
+				// the manager closes a feed that fell too far behind to take a reply
+				conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "Too many messages queued"),
+					time.Now().Add(time.Second),
+				)
+				return
+			}
+			if response.Type == communications.Close {
+				conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "Server is shutting down"),
+					time.Now().Add(time.Second),
+				)
+				return
+			}
 			conn.WriteJSON(response.Body)
 			continue
 		case recv := <-readerChannel:
@@ -118,14 +161,14 @@ func WebsocketsHandler(c *gin.Context, sCtrl *SharedController) {
 			}
 
 			for _, game := range games {
-				communications.ManagerPub.ManagerReceiver <- communications.ManagerEvent{
+				communications.ManagerPub.Post(communications.ManagerEvent{
 					Type: communications.SubscribeChannel,
 					Body: communications.ManagerEventSubscribeChannel{
 						Id:          UUID.String(),
 						ChannelType: communications.Bets,
 						Channel:     game,
 					},
-				}
+				})
 			}
 			break
 		case "unsubscribe_bets":
@@ -139,31 +182,31 @@ func WebsocketsHandler(c *gin.Context, sCtrl *SharedController) {
 			}
 
 			for _, game := range games {
-				communications.ManagerPub.ManagerReceiver <- communications.ManagerEvent{
+				communications.ManagerPub.Post(communications.ManagerEvent{
 					Type: communications.UnsubscribeChannel,
 					Body: communications.ManagerEventSubscribeChannel{
 						Id:          UUID.String(),
 						ChannelType: communications.Bets,
 						Channel:     game,
 					},
-				}
+				})
 			}
 			break
 		case "subscribe_all_bets":
-			communications.ManagerPub.ManagerReceiver <- communications.ManagerEvent{
+			communications.ManagerPub.Post(communications.ManagerEvent{
 				Type: communications.SubscribeAllBets,
 				Body: communications.ManagerEventSubscribeAllBets{
 					Id: UUID.String(),
 				},
-			}
+			})
 			break
 		case "unsubscribe_all_bets":
-			communications.ManagerPub.ManagerReceiver <- communications.ManagerEvent{
+			communications.ManagerPub.Post(communications.ManagerEvent{
 				Type: communications.UnsubscribeAllBets,
 				Body: communications.ManagerEventUnsubscribeAllBets{
 					Id: UUID.String(),
 				},
-			}
+			})
 			break
 
 		case "make_bet":
@@ -171,8 +214,9 @@ func WebsocketsHandler(c *gin.Context, sCtrl *SharedController) {
 				continue
 			}
 			bet := requests.Bet{
-				UserID: uint(userId),
-				UUID:   UUID.String(),
+				UserID:    uint(userId),
+				UUID:      UUID.String(),
+				RequestID: message.Id,
 			}
 			err := json.Unmarshal(message.Data, &bet)
 			if err != nil {
@@ -180,10 +224,10 @@ func WebsocketsHandler(c *gin.Context, sCtrl *SharedController) {
 				return
 			}
 
-			sCtrl.StatelessEngineChannel <- engine.Bet{
+			sCtrl.Engine.Submit(engine.Bet{
 				IsContinue: false,
 				Bet:        bet,
-			}
+			})
 
 			break
 
@@ -192,18 +236,19 @@ func WebsocketsHandler(c *gin.Context, sCtrl *SharedController) {
 				continue
 			}
 			bet := requests.ContinueGame{
-				UserID: uint(userId),
-				UUID:   UUID.String(),
+				UserID:    uint(userId),
+				UUID:      UUID.String(),
+				RequestID: message.Id,
 			}
 			err := json.Unmarshal(message.Data, &bet)
 			if err != nil {
 				slog.Error("Error continuing game", "err", err)
 				return
 			}
-			sCtrl.StatelessEngineChannel <- engine.Bet{
+			sCtrl.Engine.Submit(engine.Bet{
 				IsContinue: true,
 				Bet:        bet,
-			}
+			})
 
 			break
 		case "get_state":
@@ -222,7 +267,101 @@ func WebsocketsHandler(c *gin.Context, sCtrl *SharedController) {
 				slog.Error("Error getting state", "err", err)
 				return
 			}
-			response.Data = state
+			response.Data = sCtrl.Engine.Stateful.PublicState(state)
+			conn.WriteJSON(&response)
+			break
+		case "start_auto_bet":
+			if userId == 0 {
+				continue
+			}
+			start := requests.StartAutoBet{}
+			err := json.Unmarshal(message.Data, &start)
+			if err != nil {
+				slog.Error("Error starting auto bet", "err", err)
+				return
+			}
+			start.UserID = uint(userId)
+			start.UUID = UUID.String()
+
+			session, err := sCtrl.AutoBets.Start(start)
+			response.Data = autoBetReply(session, err)
+			conn.WriteJSON(&response)
+			break
+		case "stop_auto_bet":
+			if userId == 0 {
+				continue
+			}
+			stop := requests.StopAutoBet{}
+			err := json.Unmarshal(message.Data, &stop)
+			if err != nil {
+				slog.Error("Error stopping auto bet", "err", err)
+				return
+			}
+
+			session, err := sCtrl.AutoBets.Stop(uint(userId), stop.SessionID)
+			response.Data = autoBetReply(session, err)
+			conn.WriteJSON(&response)
+			break
+		case "get_auto_bets":
+			if userId == 0 {
+				continue
+			}
+			// the updates of the running sessions move to this connection
+			sessions, err := sCtrl.AutoBets.Attach(uint(userId), UUID.String())
+			if err != nil {
+				slog.Error("Error getting auto bets", "err", err)
+				return
+			}
+			response.Data = sessions
+			conn.WriteJSON(&response)
+			break
+		case "crash_bet":
+			if userId == 0 {
+				continue
+			}
+			if sCtrl.Crash == nil {
+				response.Data = crashUnavailable
+				conn.WriteJSON(&response)
+				continue
+			}
+			bet := requests.CrashBet{}
+			err := json.Unmarshal(message.Data, &bet)
+			if err != nil {
+				slog.Error("Error placing crash bet", "err", err)
+				return
+			}
+			bet.UserID = uint(userId)
+			bet.UUID = UUID.String()
+
+			response.Data = betReply(nil, sCtrl.Crash.Bet(bet))
+			conn.WriteJSON(&response)
+			break
+		case "crash_cashout":
+			if userId == 0 {
+				continue
+			}
+			if sCtrl.Crash == nil {
+				response.Data = crashUnavailable
+				conn.WriteJSON(&response)
+				continue
+			}
+
+			cashout, err := sCtrl.Crash.Cashout(uint(userId))
+			response.Data = betReply(cashout, err)
+			conn.WriteJSON(&response)
+			break
+		case "get_crash":
+			if sCtrl.Crash == nil {
+				response.Data = crashUnavailable
+				conn.WriteJSON(&response)
+				continue
+			}
+			round, err := sCtrl.Crash.Current()
+			if err != nil {
+				response.Data = betReply(nil, err)
+			} else {
+				response.Data = round
+			}
 			conn.WriteJSON(&response)
 			break
 		case "get_uuid":
@@ -237,6 +376,41 @@ func WebsocketsHandler(c *gin.Context, sCtrl *SharedController) {
 
 }
 
+var crashUnavailable = responses.BetReply{Accepted: false, Code: responses.UnknownGame, Message: "Crash is not available"}
+
+func autoBetReply(session db.AutoBetSession, err error) interface{} {
+	if err == nil {
+		return session
+	}
+	return betReply(nil, err)
+}
+
+// betReply answers a websocket request handled outside of the engine pool the same way the pool does.
+func betReply(result interface{}, err error) responses.BetReply {
+	if err == nil {
+		return responses.BetReply{Accepted: true, Result: result}
+	}
+	var betErr *engine.BetError
+	if !errors.As(err, &betErr) {
+		return responses.BetReply{Accepted: false, Code: responses.InternalError, Message: "Internal error"}
+	}
+	return responses.BetReply{Accepted: false, Code: betErr.Code, Message: betErr.Message}
+}
+
+func (c *SharedController) ReloadGames(context *gin.Context) {
+	if err := c.Engine.Reload(); err != nil {
+		slog.Error("Error reloading games", "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: err.Error()})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	response, _ := json.Marshal("Games were reloaded")
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
 func GameEndpoints(sCtrl *SharedController, router *gin.Engine) {
 	router.GET("/game/ws", func(c *gin.Context) { WebsocketsHandler(c, sCtrl) })
+	router.POST("/game/reload", AuthMiddleware(), sCtrl.AdminMiddleware(), sCtrl.ReloadGames)
 }
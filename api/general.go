@@ -61,6 +61,12 @@ func (c *SharedController) GetLeaderBoard(context *gin.Context) {
 	return
 }
 
+func (c *SharedController) GetEngineQueues(context *gin.Context) {
+	response, _ := json.Marshal(c.Engine.QueueDepths())
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
 func GeneralEndpoints(sCtrl *SharedController, router *gin.Engine) {
 	router.GET("/general/leaderboard/:type/:timeBoundaries", sCtrl.GetLeaderBoard)
+	router.GET("/general/engines", sCtrl.GetEngineQueues)
 }
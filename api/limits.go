@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+	"greekkeepers.io/backend/responses"
+)
+
+func (c *SharedController) ListBetLimits(context *gin.Context) {
+	var limits []db.BetLimit
+
+	c.Db.Find(&limits)
+
+	response, _ := json.Marshal(limits)
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
+func (c *SharedController) GetBetLimit(context *gin.Context) {
+	gameID, err := strconv.ParseUint(context.Param("gameID"), 10, 32)
+	if err != nil {
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: err.Error()})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	var coin db.Coin
+	if err := c.Db.Where("id = ?", context.Param("coinID")).First(&coin).Error; err != nil {
+		slog.Error("Coin not found", "coinId", context.Param("coinID"))
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Coin not found"})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	limit, err := c.Db.GetBetLimit(uint(gameID), coin)
+	if err != nil {
+		slog.Error("Error getting bet limit", "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Error getting bet limit"})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	response, _ := json.Marshal(limit)
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
+func (c *SharedController) SetBetLimit(context *gin.Context) {
+	var submittedLimit requests.BetLimit
+
+	if err := context.BindJSON(&submittedLimit); err != nil {
+		slog.Error("Parsing bet limit error", "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: err.Error()})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	limit := db.BetLimit{
+		GameID:    submittedLimit.GameID,
+		CoinID:    submittedLimit.CoinID,
+		MinStake:  submittedLimit.MinStake,
+		MaxStake:  submittedLimit.MaxStake,
+		MaxGames:  submittedLimit.MaxGames,
+		MaxPayout: submittedLimit.MaxPayout,
+	}
+	if err := c.Db.SetBetLimit(&limit); err != nil {
+		slog.Error("Error setting bet limit", "err", err)
+		var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Error setting bet limit"})
+		context.IndentedJSON(http.StatusInternalServerError,
+			responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+		return
+	}
+
+	response, _ := json.Marshal("Limit was set")
+	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
+}
+
+func LimitEndpoints(sCtrl *SharedController, router *gin.Engine) {
+	router.GET("/limits", sCtrl.ListBetLimits)
+	router.GET("/limits/:gameID/:coinID", sCtrl.GetBetLimit)
+	router.PUT("/limits", AuthMiddleware(), sCtrl.AdminMiddleware(), sCtrl.SetBetLimit)
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"greekkeepers.io/backend/auth"
+	"greekkeepers.io/backend/db"
 	"greekkeepers.io/backend/responses"
 )
 
@@ -61,3 +62,21 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AdminMiddleware only lets through users whose level is at least ADMIN_LEVEL.
+// It has to run after AuthMiddleware.
+func (c *SharedController) AdminMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		sub := context.GetString("uuid")
+
+		var user db.User
+		if c.Env.AdminLevel == 0 || sub == "" || c.Db.Where("id = ?", sub).First(&user).Error != nil || user.UserLevel < c.Env.AdminLevel {
+			slog.Error("Admin access denied", "userId", sub)
+			var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Access denied"})
+			context.AbortWithStatusJSON(http.StatusForbidden,
+				responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+			return
+		}
+		context.Next()
+	}
+}
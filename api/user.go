@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/blake2b"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"greekkeepers.io/backend/db"
 	"greekkeepers.io/backend/responses"
 )
@@ -198,6 +200,8 @@ func (c *SharedController) GetServerSeed(context *gin.Context) {
 	context.IndentedJSON(http.StatusOK, responses.JsonResponse[json.RawMessage]{Status: responses.Ok, Data: response})
 }
 
+var errGamesInProgress = errors.New("Games are in progress")
+
 func (c *SharedController) NewServerSeed(context *gin.Context) {
 	sub := context.GetString("uuid")
 	if sub == "" {
@@ -220,6 +224,30 @@ func (c *SharedController) NewServerSeed(context *gin.Context) {
 	}
 
 	err = c.Db.Transaction(func(tx *gorm.DB) error {
+		// the layouts of games in progress were drawn from the current seed, revealing it would give them away.
+		// Settle locks the seed while storing a state, so no game can start between the check and the update
+		var current []db.ServerSeed
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id=? AND revealed=FALSE", userId).Find(&current).Error; err != nil {
+			slog.Error("Failed adding a seed", "err", err)
+			var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Adding a seed failed"})
+			context.IndentedJSON(http.StatusInternalServerError,
+				responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+			return err
+		}
+		var games int64
+		if err := tx.Model(&db.GameState{}).Where("user_id=?", userId).Count(&games).Error; err != nil {
+			slog.Error("Failed adding a seed", "err", err)
+			var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Adding a seed failed"})
+			context.IndentedJSON(http.StatusInternalServerError,
+				responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+			return err
+		}
+		if games > 0 {
+			var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Finish the games in progress before changing the server seed"})
+			context.IndentedJSON(http.StatusConflict,
+				responses.JsonResponse[json.RawMessage]{Status: responses.Err, Data: err_msg})
+			return errGamesInProgress
+		}
 		if err := tx.Model(&db.ServerSeed{}).Where("user_id=? AND revealed=FALSE", userId).Update("revealed", true).Error; err != nil {
 			slog.Error("Failed adding a seed", "err", err)
 			var err_msg, _ = json.Marshal(responses.ErrorMessage{Message: "Adding a seed failed"})
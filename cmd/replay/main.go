@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"greekkeepers.io/backend/config"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/engine"
+	"greekkeepers.io/backend/games"
+)
+
+// replay streams stored bets, plays them again with the current game code and the parameters they were played with
+// and reports every bet that doesn't settle the same way anymore.
+// Unlike the verify endpoint it also uses server seeds that weren't revealed yet.
+// Crash bets are skipped, their rounds are played from the hash chain and not from the seeds.
+// The exit code is 2 when any bet drifted.
+func main() {
+	gameName := flag.String("game", "", "only replay bets of this game")
+	from := flag.String("from", "", "only replay bets placed at or after this date (2006-01-02 or RFC 3339)")
+	to := flag.String("to", "", "only replay bets placed before this date (2006-01-02 or RFC 3339)")
+	serverSeedId := flag.Uint("server-seed", 0, "only replay bets played with this server seed id")
+	userSeedId := flag.Uint("user-seed", 0, "only replay bets played with this user seed id")
+	flag.Parse()
+
+	env := config.Env{}
+	err := config.LoadEnv(&env)
+	if err != nil {
+		slog.Error("Error loading config", "err", err)
+		os.Exit(1)
+	}
+
+	DBUrl := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s", env.DBHost, env.DBPort, env.DBUser, env.DBName, env.DBUserPwd)
+	DB, err := gorm.Open(postgres.Open(DBUrl), &gorm.Config{})
+	if err != nil {
+		slog.Error("Error connecting to db", "err", err)
+		os.Exit(1)
+	}
+
+	var gamesRaw []db.Game
+	if err := DB.Find(&gamesRaw).Error; err != nil {
+		slog.Error("Error getting games", "err", err)
+		os.Exit(1)
+	}
+	gamesById := make(map[uint]db.Game, len(gamesRaw))
+	for _, game := range gamesRaw {
+		gamesById[game.ID] = game
+	}
+
+	query := DB.Model(&db.Bet{}).Order("id")
+	if *gameName != "" {
+		query = query.Where("game_id = (SELECT id FROM games WHERE name = ?)", *gameName)
+	}
+	if *from != "" {
+		query = query.Where("timestamp >= ?", parseDate("from", *from))
+	}
+	if *to != "" {
+		query = query.Where("timestamp < ?", parseDate("to", *to))
+	}
+	if *serverSeedId != 0 {
+		query = query.Where("server_seed_id = ?", *serverSeedId)
+	}
+	if *userSeedId != 0 {
+		query = query.Where("user_seed_id = ?", *userSeedId)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		slog.Error("Error querying bets", "err", err)
+		os.Exit(1)
+	}
+	defer rows.Close()
+
+	// parameters of the game versions, shared by every bet of a game
+	versionDb := &db.DB{DB: DB}
+	paramsByGame := make(map[uint]engine.ParamsLookup, len(gamesRaw))
+	for _, game := range gamesRaw {
+		paramsByGame[game.ID] = engine.VersionParams(versionDb, game)
+	}
+	seeds := seedCache{Db: DB, userSeeds: map[uint]string{}, serverSeeds: map[uint]string{}}
+	checked, drifted, skipped, skippedCrash, failed := 0, 0, 0, 0, 0
+	for rows.Next() {
+		var bet db.Bet
+		if err := DB.ScanRows(rows, &bet); err != nil {
+			slog.Error("Error reading bet", "err", err)
+			os.Exit(1)
+		}
+
+		game := gamesById[bet.GameID]
+		if game.Name == games.CrashName {
+			skippedCrash++
+			continue
+		}
+		steps, err := engine.ParseSteps(bet.Steps)
+		if errors.Is(err, engine.ErrNoSteps) {
+			skipped++
+			continue
+		}
+
+		checked++
+		params, ok := paramsByGame[bet.GameID]
+		if !ok {
+			params = engine.VersionParams(versionDb, game)
+		}
+		var result db.GameResult
+		if err == nil {
+			result, err = engine.ReplaySteps(game.Name, params, steps, seeds.lookup)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("bet %d (%s): replay failed: %v\n", bet.ID, game.Name, err)
+			continue
+		}
+
+		outcomesMatch, profitsMatch, err := engine.ResultMatches(bet, result)
+		if err != nil {
+			failed++
+			fmt.Printf("bet %d (%s): comparing failed: %v\n", bet.ID, game.Name, err)
+			continue
+		}
+		if outcomesMatch && profitsMatch {
+			continue
+		}
+
+		drifted++
+		fmt.Printf("bet %d (%s): outcomes match: %v, profits match: %v\n", bet.ID, game.Name, outcomesMatch, profitsMatch)
+		fmt.Printf("  stored:   outcomes %s profits %s profit %s\n", bet.Outcomes, bet.Profits, bet.Profit)
+		fmt.Printf("  replayed: outcomes %v profits %v profit %s\n", result.Outcomes, result.Profits, result.TotalProfit)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("Error reading bets", "err", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("checked: %d, drifted: %d, failed: %d, skipped without steps: %d, skipped Crash: %d\n", checked, drifted, failed, skipped, skippedCrash)
+	if drifted > 0 || failed > 0 {
+		os.Exit(2)
+	}
+}
+
+// seedCache loads every seed once, bets of a user mostly share the same seeds.
+type seedCache struct {
+	Db          *gorm.DB
+	userSeeds   map[uint]string
+	serverSeeds map[uint]string
+}
+
+func (c *seedCache) lookup(step db.BetStep) (string, string, error) {
+	userSeed, ok := c.userSeeds[step.UserSeedID]
+	if !ok {
+		seed := db.UserSeed{}
+		if err := c.Db.Where("id = ?", step.UserSeedID).First(&seed).Error; err != nil {
+			return "", "", err
+		}
+		userSeed = seed.UserSeed
+		c.userSeeds[step.UserSeedID] = userSeed
+	}
+
+	serverSeed, ok := c.serverSeeds[step.ServerSeedID]
+	if !ok {
+		seed := db.ServerSeed{}
+		if err := c.Db.Where("id = ?", step.ServerSeedID).First(&seed).Error; err != nil {
+			return "", "", err
+		}
+		serverSeed = seed.ServerSeed
+		c.serverSeeds[step.ServerSeedID] = serverSeed
+	}
+
+	return userSeed, serverSeed, nil
+}
+
+func parseDate(name string, value string) time.Time {
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Error parsing -%s, expected 2006-01-02 or RFC 3339\n", name)
+	os.Exit(1)
+	return time.Time{}
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/postgres"
@@ -32,18 +39,41 @@ func main() {
 		slog.Info("Connected to db")
 	}
 
-	statefulBetChannel := make(chan engine.Bet)
-	statelessBetChannel := make(chan engine.Bet)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	communications.New(DB)
 	go communications.ManagerPub.Run()
-	sCtrl := api.SharedController{Db: &db.DB{DB}, Env: &env, Manager: communications.ManagerPub, StatelessEngineChannel: statelessBetChannel}
 
-	stateless := engine.NewStatelessEngine(statelessBetChannel, statefulBetChannel, communications.ManagerPub, &db.DB{DB})
-	stateful := engine.NewStatefulEngine(statefulBetChannel, communications.ManagerPub, &db.DB{DB})
+	stateless := engine.NewStatelessEngine(communications.ManagerPub, &db.DB{DB: DB})
+	stateful := engine.NewStatefulEngine(communications.ManagerPub, &db.DB{DB: DB})
 
-	go stateless.Run()
-	go stateful.Run()
+	pool := engine.NewPool(env.ENGINES, stateless, stateful)
+	pool.Run()
+	go pool.ListenGameChanges(ctx, DBUrl)
+	go pool.SweepExpiredGames(ctx, time.Duration(env.StateTTL)*time.Second)
+
+	autoBets := engine.NewAutoBets(ctx, pool)
+	if err := autoBets.Resume(); err != nil {
+		slog.Error("Error resuming auto bets", "err", err)
+	}
+
+	crashDone := make(chan struct{})
+	crash, err := engine.NewCrashEngine(communications.ManagerPub, &db.DB{DB: DB}, pool.Hooks)
+	if err != nil {
+		slog.Warn("Crash is disabled", "err", err)
+		close(crashDone)
+	} else {
+		if err := crash.Resume(); err != nil {
+			slog.Error("Error resuming crash bets", "err", err)
+		}
+		go func() {
+			defer close(crashDone)
+			crash.Run(ctx)
+		}()
+	}
+
+	sCtrl := api.SharedController{Db: &db.DB{DB: DB}, Env: &env, Manager: communications.ManagerPub, Engine: pool, AutoBets: autoBets, Crash: crash}
 
 	router.Use(api.CORSMiddleware())
 
@@ -54,6 +84,57 @@ func main() {
 	api.BetsEndpoints(&sCtrl, router)
 	api.CoinEndpoints(&sCtrl, router)
 	api.ReferalEndpoints(&sCtrl, router)
-	router.Run(fmt.Sprintf("%s:%s", env.ServerHost, env.ServerPort))
+	api.LimitEndpoints(&sCtrl, router)
+	api.BankrollEndpoints(&sCtrl, router)
+	api.CrashEndpoints(&sCtrl, router)
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf("%s:%s", env.ServerHost, env.ServerPort),
+		Handler: router,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Error running server", "err", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("Shutting down", "timeout", env.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(env.ShutdownTimeout)*time.Second)
+	defer cancel()
 
+	// stop taking new connections, then settle the bets that are already queued
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down server", "err", err)
+	}
+	if err := pool.Stop(shutdownCtx); err != nil {
+		slog.Error("Error stopping engines", "err", err)
+	}
+	// the crash round in progress refunds its stakes
+	select {
+	case <-crashDone:
+	case <-shutdownCtx.Done():
+		slog.Error("Crash engine didn't stop in time")
+	}
+
+	// the manager sends close frames to the websockets that are still connected
+	select {
+	case communications.ManagerPub.Stop <- true:
+		<-communications.ManagerPub.Done
+		if err := api.WaitConnections(shutdownCtx); err != nil {
+			slog.Error("Websockets didn't close in time", "err", err)
+		}
+	case <-shutdownCtx.Done():
+		slog.Error("Manager didn't stop in time")
+	}
+
+	sqlDB, err := DB.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
+	if err != nil {
+		slog.Error("Error closing db", "err", err)
+	}
+	slog.Info("Shutdown complete")
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/engine"
+	"greekkeepers.io/backend/games"
+	"greekkeepers.io/backend/requests"
+)
+
+// simulate plays a game many times with the server RNG and reports its return to player.
+// Stateful games are driven by -script, a JSON list of the continue data sent after the start,
+// a game that is still unfinished when the script runs out is resolved if the game supports it.
+// With -min-rtp or -max-rtp the exit code is 2 when the RTP falls outside of the bounds.
+func main() {
+	gameName := flag.String("game", "", "game name, one of: "+strings.Join(games.Registered(), ", "))
+	params := flag.String("params", "", "game parameters as stored in the games table")
+	paramsFile := flag.String("params-file", "", "read the game parameters from a file")
+	data := flag.String("data", "", "bet data")
+	script := flag.String("script", "[]", "continue data of stateful games as a JSON list of strings")
+	amount := flag.String("amount", "1", "amount of a single round")
+	rounds := flag.Uint64("rounds", 1000000, "number of rounds to play")
+	serverSeed := flag.String("server-seed", "", "server seed, random when empty")
+	clientSeed := flag.String("client-seed", "simulation", "client seed")
+	minRTP := flag.Float64("min-rtp", -1, "fail when the RTP is below, -1 disables the check")
+	maxRTP := flag.Float64("max-rtp", -1, "fail when the RTP is above, -1 disables the check")
+	flag.Parse()
+
+	if *gameName == "" || *rounds == 0 {
+		fail("-game is required and -rounds must be positive")
+	}
+	if *paramsFile != "" {
+		content, err := os.ReadFile(*paramsFile)
+		if err != nil {
+			fail("Error reading params file: " + err.Error())
+		}
+		*params = string(content)
+	}
+	if *serverSeed == "" {
+		*serverSeed = uuid.NewString()
+	}
+	betAmount, err := decimal.NewFromString(*amount)
+	if err != nil || !betAmount.IsPositive() {
+		fail("-amount must be a positive number")
+	}
+	var continueData []string
+	if err := json.Unmarshal([]byte(*script), &continueData); err != nil {
+		fail("Error parsing script: " + err.Error())
+	}
+
+	play, err := newRound(*gameName, *params, continueData)
+	if err != nil {
+		fail(err.Error())
+	}
+
+	bet := requests.Bet{Amount: betAmount, NumGames: 1, Data: *data}
+	stats := newStats()
+	// every step takes the next nonce, the same way it does on the server
+	nonce := uint64(0)
+	numbers := func(amount uint64) []uint64 {
+		numbers := engine.GenerateRandomNumbers(*clientSeed, *serverSeed, nonce, amount)
+		nonce++
+		return numbers
+	}
+	for i := range *rounds {
+		payout, debit, err := play(bet, numbers)
+		if err != nil {
+			fail(fmt.Sprintf("Round %d failed: %v", i, err))
+		}
+		stats.add(payout.Div(betAmount).InexactFloat64(), debit.Div(betAmount).InexactFloat64())
+	}
+
+	stats.print(*gameName, *serverSeed, *clientSeed)
+
+	rtp := stats.rtp()
+	if (*minRTP >= 0 && rtp < *minRTP) || (*maxRTP >= 0 && rtp > *maxRTP) {
+		fmt.Fprintf(os.Stderr, "RTP %.6f is outside of [%v, %v]\n", rtp, *minRTP, *maxRTP)
+		os.Exit(2)
+	}
+}
+
+// round plays a single round and returns the total payout of the bet
+// and the stake taken on top of the bet amount by the continue steps.
+type round func(bet requests.Bet, numbers func(amount uint64) []uint64) (decimal.Decimal, decimal.Decimal, error)
+
+func newRound(gameName string, params string, continueData []string) (round, error) {
+	stateless, err := engine.ParseStatelessGame(gameName, params)
+	if err != nil {
+		return nil, err
+	}
+	if stateless != nil {
+		return func(bet requests.Bet, numbers func(uint64) []uint64) (decimal.Decimal, decimal.Decimal, error) {
+			result, err := stateless.Play(bet, numbers(stateless.NumbersPerBet()))
+			return result.TotalProfit, decimal.Zero, err
+		}, nil
+	}
+
+	stateful, err := engine.ParseStatefulGame(gameName, params)
+	if err != nil {
+		return nil, err
+	}
+	if stateful == nil {
+		return nil, fmt.Errorf("Game %s is not registered", gameName)
+	}
+	return func(bet requests.Bet, numbers func(uint64) []uint64) (decimal.Decimal, decimal.Decimal, error) {
+		result, err := stateful.StartPlaying(bet, numbers(stateful.NumbersPerBet()))
+		if err != nil {
+			return decimal.Zero, decimal.Zero, err
+		}
+
+		state := db.GameState{Amount: bet.Amount, BetInfo: bet.Data, State: result.Data}
+		for _, data := range continueData {
+			if result.Finished {
+				break
+			}
+			result, err = stateful.ContinuePlaying(state, requests.ContinueGame{Data: data}, numbers(games.NumbersPerStep(stateful)))
+			if err != nil {
+				return decimal.Zero, decimal.Zero, err
+			}
+			state.Amount = state.Amount.Add(result.Debit)
+			state.State = result.Data
+		}
+
+		if !result.Finished {
+			resolvable, ok := stateful.(games.ResolvableGame)
+			if !ok {
+				return decimal.Zero, decimal.Zero, errors.New("Script ended before the game finished")
+			}
+			result, err = resolvable.Resolve(state)
+			if err != nil {
+				return decimal.Zero, decimal.Zero, err
+			}
+		}
+		return result.TotalProfit, state.Amount.Sub(bet.Amount), nil
+	}, nil
+}
+
+type stats struct {
+	rounds        uint64
+	hits          uint64
+	sum           float64
+	staked        float64
+	sumSquares    float64
+	maxMultiplier float64
+	multipliers   map[float64]uint64
+}
+
+func newStats() *stats {
+	return &stats{multipliers: make(map[float64]uint64)}
+}
+
+// add counts a round that paid multiplier times the bet amount, extra is the stake
+// taken on top of the bet amount in the same unit.
+func (s *stats) add(multiplier float64, extra float64) {
+	s.rounds++
+	s.sum += multiplier
+	s.staked += 1 + extra
+	s.sumSquares += multiplier * multiplier
+	if multiplier > 0 {
+		s.hits++
+	}
+	s.maxMultiplier = math.Max(s.maxMultiplier, multiplier)
+	s.multipliers[multiplier]++
+}
+
+func (s *stats) rtp() float64 {
+	return s.sum / s.staked
+}
+
+func (s *stats) variance() float64 {
+	mean := s.sum / float64(s.rounds)
+	return s.sumSquares/float64(s.rounds) - mean*mean
+}
+
+func (s *stats) print(gameName string, serverSeed string, clientSeed string) {
+	fmt.Printf("game:          %s\n", gameName)
+	fmt.Printf("seeds:         %s %s\n", serverSeed, clientSeed)
+	fmt.Printf("rounds:        %d\n", s.rounds)
+	fmt.Printf("rtp:           %.6f\n", s.rtp())
+	fmt.Printf("house edge:    %.6f\n", 1-s.rtp())
+	fmt.Printf("variance:      %.6f\n", s.variance())
+	fmt.Printf("std deviation: %.6f\n", math.Sqrt(s.variance()))
+	fmt.Printf("hit frequency: %.6f\n", float64(s.hits)/float64(s.rounds))
+	fmt.Printf("max win:       %vx\n", s.maxMultiplier)
+
+	multipliers := make([]float64, 0, len(s.multipliers))
+	for multiplier := range s.multipliers {
+		multipliers = append(multipliers, multiplier)
+	}
+	sort.Float64s(multipliers)
+
+	fmt.Println("multiplier     frequency     rounds")
+	for _, multiplier := range multipliers {
+		count := s.multipliers[multiplier]
+		fmt.Printf("%-14v %-13.6f %d\n", multiplier, float64(count)/float64(s.rounds), count)
+	}
+}
+
+func fail(message string) {
+	fmt.Fprintln(os.Stderr, message)
+	os.Exit(1)
+}
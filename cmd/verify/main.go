@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/engine"
+	"greekkeepers.io/backend/games"
+	"greekkeepers.io/backend/requests"
+)
+
+// verify replays a bet offline with the same game code the server runs.
+// Multi step games are replayed from the steps list returned with the bet,
+// single step bets can be described with -amount, -num-games and -data instead.
+func main() {
+	serverSeed := flag.String("server-seed", "", "revealed server seed")
+	clientSeed := flag.String("client-seed", "", "client (user) seed")
+	nonce := flag.Uint64("nonce", 0, "nonce of the bet")
+	timestamp := flag.Uint64("timestamp", 0, "unix timestamp of the bet, for bets placed before nonces")
+	gameName := flag.String("game", "", "game name, one of: "+strings.Join(games.Registered(), ", "))
+	params := flag.String("params", "", "game parameters as stored in the games table")
+	data := flag.String("data", "", "bet data")
+	amount := flag.String("amount", "1", "amount of a single game")
+	numGames := flag.Uint64("num-games", 1, "number of games in the bet")
+	stopLoss := flag.String("stop-loss", "0", "stop loss of the bet")
+	stopWin := flag.String("stop-win", "0", "stop win of the bet")
+	steps := flag.String("steps", "", "steps of the bet as JSON, overrides the single bet flags")
+	flag.Parse()
+
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if *serverSeed == "" || *clientSeed == "" || *gameName == "" {
+		fail("-server-seed, -client-seed and -game are required")
+	}
+	if set["nonce"] && set["timestamp"] {
+		fail("-nonce and -timestamp can't be used together")
+	}
+	if set["timestamp"] {
+		*nonce = *timestamp
+	}
+
+	var betSteps []db.BetStep
+	if *steps != "" {
+		parsed, err := engine.ParseSteps(*steps)
+		if err != nil {
+			fail("Error parsing steps: " + err.Error())
+		}
+		betSteps = parsed
+	} else {
+		bet := requests.Bet{
+			Amount:   parseDecimal("amount", *amount),
+			NumGames: *numGames,
+			Data:     *data,
+			StopLoss: parseDecimal("stop-loss", *stopLoss),
+			StopWin:  parseDecimal("stop-win", *stopWin),
+		}
+		request, _ := json.Marshal(bet)
+		betSteps = []db.BetStep{{Nonce: *nonce, Request: request}}
+	}
+
+	// the parameters of the game version the steps were played with
+	result, err := engine.ReplaySteps(*gameName, func(db.BetStep) (string, error) { return *params, nil }, betSteps, func(step db.BetStep) (string, string, error) {
+		return *clientSeed, *serverSeed, nil
+	})
+	if err != nil {
+		fail("Error replaying bet: " + err.Error())
+	}
+
+	output, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(output))
+}
+
+func parseDecimal(name string, value string) decimal.Decimal {
+	parsed, err := decimal.NewFromString(value)
+	if err != nil {
+		fail(fmt.Sprintf("Error parsing -%s: %v", name, err))
+	}
+	return parsed
+}
+
+func fail(message string) {
+	fmt.Fprintln(os.Stderr, message)
+	os.Exit(1)
+}
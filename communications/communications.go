@@ -14,8 +14,16 @@ type BroadcastType int
 const (
 	NewBet BroadcastType = iota
 	StateUpdate
+	Reply
+	// Close tells the websocket to send a close frame and disconnect
+	Close
+	Crash
 )
 
+// FeedSize is the amount of broadcasts a websocket feed buffers before the manager
+// starts dropping broadcasts for it. A reply or a close that doesn't fit closes the feed.
+const FeedSize = 64
+
 type Broadcast struct {
 	Type BroadcastType
 	Body interface{}
@@ -32,6 +40,9 @@ const (
 	UnsubscribeAllBets
 	PropagateBet
 	PropagateState
+	ReplyFeed
+	UpdateGames
+	PropagateCrash
 )
 
 type ManagerEvent struct {
@@ -47,6 +58,25 @@ type ManagerEventUnsubscribeFeed struct {
 	Id string
 }
 
+// ManagerEventUpdateGames carries the ids of all the games after a reload.
+type ManagerEventUpdateGames struct {
+	GameIds []uint
+}
+
+// ManagerEventCrash is sent to every subscriber of the Crash game.
+type ManagerEventCrash struct {
+	GameID uint
+	Update responses.CrashUpdate
+}
+
+// ManagerEventReply is delivered only to the feed with the given Id
+// as the response to the websocket request RequestId.
+type ManagerEventReply struct {
+	Id        string
+	RequestId uint
+	Data      interface{}
+}
+
 type ChannelType int
 
 const (
@@ -79,22 +109,41 @@ type Manager struct {
 	SubscriptionsBets map[uint]map[string]bool
 	ManagerReceiver   chan ManagerEvent
 	Stop              chan bool
+	// Done is closed once Run returns, nothing reads ManagerReceiver after that
+	Done chan struct{}
 }
 
 func (m *Manager) Run() {
 	slog.Info("Starting manager")
-	for true {
+	defer close(m.Done)
+	for {
 		select {
 		case event := <-m.ManagerReceiver:
 			slog.Info("Manager got event", "event", event)
 			m.ProcessEvent(event)
 		case <-m.Stop:
-			slog.Info("Manager exiting")
-			break
+			slog.Info("Manager exiting", "feeds", len(m.Feeds))
+			m.CloseFeeds()
+			return
 		}
 	}
 }
 
+// Post hands the event to Run, it is dropped once the manager has stopped.
+func (m *Manager) Post(event ManagerEvent) {
+	select {
+	case m.ManagerReceiver <- event:
+	case <-m.Done:
+	}
+}
+
+// CloseFeeds asks every connected websocket to disconnect.
+func (m *Manager) CloseFeeds() {
+	for id, feed := range m.Feeds {
+		m.send(id, feed, Broadcast{Type: Close})
+	}
+}
+
 func New(Db *gorm.DB) *Manager {
 	var games []db.Game
 	err := Db.Find(&games).Error
@@ -113,6 +162,7 @@ func New(Db *gorm.DB) *Manager {
 		SubscriptionsBets: subscriptions,
 		ManagerReceiver:   make(chan ManagerEvent),
 		Stop:              make(chan bool),
+		Done:              make(chan struct{}),
 	}
 	return ManagerPub
 }
@@ -129,7 +179,7 @@ func (m *Manager) PropagateBet(bet responses.Bet) {
 			slog.Error("Feed not found", "sub", sub)
 			continue
 		}
-		feed <- Broadcast{Type: NewBet, Body: bet}
+		m.send(sub, feed, Broadcast{Type: NewBet, Body: bet})
 	}
 }
 
@@ -145,8 +195,80 @@ func (m *Manager) PropagateState(state db.GameState) {
 			slog.Error("Feed not found", "sub", sub)
 			continue
 		}
-		feed <- Broadcast{Type: NewBet, Body: state}
+		m.send(sub, feed, Broadcast{Type: NewBet, Body: state})
+	}
+}
+
+func (m *Manager) PropagateCrash(crash ManagerEventCrash) {
+	subs, ok := m.SubscriptionsBets[crash.GameID]
+	if !ok {
+		slog.Error("Game not found", "game id", crash.GameID)
+		return
+	}
+	for sub := range subs {
+		feed, ok := m.Feeds[sub]
+		if !ok {
+			slog.Error("Feed not found", "sub", sub)
+			continue
+		}
+		m.send(sub, feed, Broadcast{Type: Crash, Body: crash.Update})
+	}
+}
+
+// UpdateGames starts tracking subscriptions for new games
+// and drops the subscriptions of the games that are gone.
+func (m *Manager) UpdateGames(update ManagerEventUpdateGames) {
+	current := make(map[uint]bool, len(update.GameIds))
+	for _, gameId := range update.GameIds {
+		current[gameId] = true
+		if _, ok := m.SubscriptionsBets[gameId]; !ok {
+			m.SubscriptionsBets[gameId] = make(map[string]bool)
+		}
+	}
+	for gameId := range m.SubscriptionsBets {
+		if !current[gameId] {
+			delete(m.SubscriptionsBets, gameId)
+		}
+	}
+}
+
+func (m *Manager) Reply(reply ManagerEventReply) {
+	feed, ok := m.Feeds[reply.Id]
+	if !ok {
+		slog.Warn("Feed for reply not found", "id", reply.Id)
+		return
+	}
+	m.send(reply.Id, feed, Broadcast{
+		Type: Reply,
+		Body: responses.WSresponse{Id: reply.RequestId, Data: reply.Data},
+	})
+}
+
+// send never blocks the manager on a slow or already closed websocket.
+// Bets and states of the subscribed games can be dropped, a reply or a close can't:
+// when they don't fit the feed is closed and the websocket disconnects instead of missing them.
+func (m *Manager) send(id string, feed chan Broadcast, broadcast Broadcast) {
+	select {
+	case feed <- broadcast:
+		return
+	default:
+	}
+
+	if broadcast.Type != Reply && broadcast.Type != Close {
+		slog.Warn("Feed is full, dropping message", "id", id)
+		return
 	}
+	slog.Warn("Feed is full, closing it", "id", id, "type", broadcast.Type)
+	m.removeFeed(id)
+	close(feed)
+}
+
+// removeFeed stops sending anything to the feed with the given id.
+func (m *Manager) removeFeed(id string) {
+	for _, subs := range m.SubscriptionsBets {
+		delete(subs, id)
+	}
+	delete(m.Feeds, id)
 }
 
 func (m *Manager) ProcessEvent(event ManagerEvent) {
@@ -165,6 +287,27 @@ func (m *Manager) ProcessEvent(event ManagerEvent) {
 		}
 		m.PropagateState(state)
 		break
+	case ReplyFeed:
+		reply, ok := event.Body.(ManagerEventReply)
+		if !ok {
+			panic(fmt.Sprintf("Cannot convert ManagerEventReply %#v", event))
+		}
+		m.Reply(reply)
+		break
+	case PropagateCrash:
+		crash, ok := event.Body.(ManagerEventCrash)
+		if !ok {
+			panic(fmt.Sprintf("Cannot convert ManagerEventCrash %#v", event))
+		}
+		m.PropagateCrash(crash)
+		break
+	case UpdateGames:
+		update, ok := event.Body.(ManagerEventUpdateGames)
+		if !ok {
+			panic(fmt.Sprintf("Cannot convert ManagerEventUpdateGames %#v", event))
+		}
+		m.UpdateGames(update)
+		break
 	case SubscribeAllBets:
 		sub, ok := event.Body.(ManagerEventSubscribeAllBets)
 		if !ok {
@@ -217,6 +360,12 @@ func (m *Manager) ProcessEvent(event ManagerEvent) {
 		break
 	case UnsubscribeChannel:
 	case UnsubscribeFeed:
+		sub, ok := event.Body.(ManagerEventUnsubscribeFeed)
+		if !ok {
+			panic(fmt.Sprintf("Cannot convert UnsubscribeFeed %#v", event))
+		}
+		m.removeFeed(sub.Id)
+		break
 	default:
 		panic(fmt.Sprintf("unexpected communications.ManagerEventType: %#v", event.Type))
 	}
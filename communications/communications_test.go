@@ -0,0 +1,67 @@
+package communications
+
+import (
+	"testing"
+
+	"greekkeepers.io/backend/responses"
+)
+
+func testManager(feed chan Broadcast) *Manager {
+	return &Manager{
+		Feeds:             map[string]chan Broadcast{"socket": feed},
+		SubscriptionsBets: map[uint]map[string]bool{1: {"socket": true}},
+	}
+}
+
+func TestSendToFullFeed(t *testing.T) {
+	tests := []struct {
+		name   string
+		send   func(m *Manager)
+		closed bool
+	}{
+		{
+			name:   "bet of a subscribed game is dropped",
+			send:   func(m *Manager) { m.PropagateBet(responses.Bet{GameID: 1}) },
+			closed: false,
+		},
+		{
+			name:   "reply closes the feed",
+			send:   func(m *Manager) { m.Reply(ManagerEventReply{Id: "socket", RequestId: 7}) },
+			closed: true,
+		},
+		{
+			name:   "shutdown closes the feed",
+			send:   func(m *Manager) { m.CloseFeeds() },
+			closed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := make(chan Broadcast, 1)
+			feed <- Broadcast{Type: NewBet}
+			m := testManager(feed)
+			tt.send(m)
+
+			<-feed
+			open := true
+			select {
+			case _, open = <-feed:
+				if open {
+					t.Fatal("feed got a message past its size")
+				}
+			default:
+			}
+			switch {
+			case tt.closed && open:
+				t.Error("feed wasn't closed")
+			case tt.closed:
+				if _, ok := m.Feeds["socket"]; ok || m.SubscriptionsBets[1]["socket"] {
+					t.Error("closed feed is still subscribed")
+				}
+			case !tt.closed && (!open || len(m.Feeds) != 1):
+				t.Error("feed was closed")
+			}
+		})
+	}
+}
@@ -21,6 +21,13 @@ type Env struct {
 	RefreshTokenValidity uint64 `envconfig:"REFRESH_TOKEN_VALIDITY"`
 
 	ENGINES uint16 `envconfig:"ENGINES"`
+	// seconds to wait for queued bets and open connections on shutdown
+	ShutdownTimeout uint64 `envconfig:"SHUTDOWN_TIMEOUT" default:"30"`
+	// seconds an unfinished game may sit idle before it is resolved, games can override it with state_ttl
+	StateTTL uint64 `envconfig:"STATE_TTL" default:"3600"`
+
+	// users with at least this level can use the admin endpoints, 0 disables them
+	AdminLevel int64 `envconfig:"ADMIN_LEVEL"`
 }
 
 func LoadEnv(cfg *Env) error {
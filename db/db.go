@@ -2,63 +2,222 @@ package db
 
 import (
 	"errors"
-	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"greekkeepers.io/backend/responses"
 )
 
+var ErrInsufficientBalance = errors.New("Amount is greater, than balance")
+var ErrDuplicateBet = errors.New("Idempotency key was already used")
+var ErrSeedRevealed = errors.New("Server seed was already revealed")
+var ErrAutoBetRunning = errors.New("Auto bet session is already active")
+
+// Limits used for games and coins that have no row in bet_limits.
+const DefaultMaxGames = 100
+
+var DefaultMaxStakeUsd = decimal.New(50, 0)
+
 type DB struct {
 	*gorm.DB
 }
 
-func (db *DB) DecreaseBalance(userId uint, coinId uint, amount decimal.Decimal) error {
-	err := db.Transaction(func(tx *gorm.DB) error {
+// Settlement holds every change a single bet or game step makes.
+// Settle applies all of them or none.
+type Settlement struct {
+	UserID uint
+	CoinID uint
+	GameID uint
+
+	// Debit is taken from the balance, Credit is paid to it.
+	Debit  decimal.Decimal
+	Credit decimal.Decimal
+
+	// Bet is written when the game is finished.
+	Bet *Bet
+	// State replaces the game state of the user, RemoveState only deletes it.
+	State       *GameState
+	RemoveState bool
+	// StateKey is the idempotency key of a stateful step, it's kept in state_keys
+	// and linked to the bet when the game is settled.
+	StateKey *string
+}
+
+func (db *DB) Settle(settlement Settlement) error {
+	err := db.settle(settlement)
 
-		balance := Amount{}
-		err := tx.Where("coin_id = ? AND user_id = ?", coinId, userId).First(&balance).Error
-		if err != nil {
-			return err
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" &&
+		(pgErr.ConstraintName == "bet_idempotency_unique_idx" || pgErr.ConstraintName == "state_idempotency_unique_idx" ||
+			pgErr.ConstraintName == "state_key_unique_idx") {
+		return ErrDuplicateBet
+	}
+	return err
+}
+
+func (db *DB) settle(settlement Settlement) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if !settlement.Debit.IsZero() || !settlement.Credit.IsZero() {
+			balance := Amount{}
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("coin_id = ? AND user_id = ?", settlement.CoinID, settlement.UserID).
+				First(&balance).Error
+			if err != nil {
+				return err
+			}
+
+			if settlement.Debit.GreaterThan(balance.Amount) {
+				return ErrInsufficientBalance
+			}
+
+			newAmount := balance.Amount.Sub(settlement.Debit).Add(settlement.Credit)
+			if err := tx.Model(&Amount{}).Where("user_id=? AND coin_id=?", settlement.UserID, settlement.CoinID).Update("amount", newAmount).Error; err != nil {
+				return err
+			}
+
+			// coins without a bankroll row aren't tracked
+			houseResult := settlement.Debit.Sub(settlement.Credit)
+			if err := tx.Model(&Bankroll{}).Where("coin_id=?", settlement.CoinID).Update("amount", gorm.Expr("amount + ?", houseResult)).Error; err != nil {
+				return err
+			}
+		}
+
+		if settlement.StateKey != nil {
+			stateKey := StateKey{
+				IdempotencyKey: *settlement.StateKey,
+				UserID:         settlement.UserID,
+				GameID:         settlement.GameID,
+				CoinID:         settlement.CoinID,
+			}
+			if err := tx.Create(&stateKey).Error; err != nil {
+				return err
+			}
+		}
+
+		if settlement.Bet != nil {
+			if err := tx.Create(settlement.Bet).Error; err != nil {
+				return err
+			}
+		}
+
+		// the keys of every step of a finished game now point to its bet
+		if settlement.Bet != nil && settlement.RemoveState {
+			err := tx.Model(&StateKey{}).
+				Where("game_id=? AND user_id=? AND coin_id=? AND bet_id IS NULL", settlement.GameID, settlement.UserID, settlement.CoinID).
+				Update("bet_id", settlement.Bet.ID).Error
+			if err != nil {
+				return err
+			}
 		}
 
-		if amount.GreaterThan(balance.Amount) {
-			return errors.New("Amount is greater, than balance")
+		if settlement.RemoveState || settlement.State != nil {
+			err := tx.Where("game_id=? AND user_id=? AND coin_id=?", settlement.GameID, settlement.UserID, settlement.CoinID).Delete(&GameState{}).Error
+			if err != nil {
+				return err
+			}
 		}
 
-		if err := tx.Model(&Amount{}).Where("user_id=? AND coin_id=?", userId, coinId).Update("amount", balance.Amount.Sub(amount)).Error; err != nil {
-			return err
+		if settlement.State != nil {
+			// the state was drawn from the server seed, it must stay secret until the game is over.
+			// NewServerSeed locks the seed too, so it can't be revealed while the state is stored
+			seed := ServerSeed{}
+			err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("id = ?", settlement.State.ServerSeedID).First(&seed).Error
+			if err != nil {
+				return err
+			}
+			if seed.Revealed {
+				return ErrSeedRevealed
+			}
+			if err := tx.Create(settlement.State).Error; err != nil {
+				return err
+			}
 		}
 
 		return nil
 	})
+}
 
-	return err
+// NextNonce returns the nonce of the next bet played with the pair of seeds.
+// Nonces start at 0 and are never handed out twice for the same pair.
+func (db *DB) NextNonce(userSeedId uint, serverSeedId uint) (uint64, error) {
+	var nonce uint64
+	err := db.Raw(`INSERT INTO seed_nonces (user_seed_id, server_seed_id, nonce) VALUES (?, ?, 0)
+		ON CONFLICT (user_seed_id, server_seed_id) DO UPDATE SET nonce = seed_nonces.nonce + 1
+		RETURNING nonce`, userSeedId, serverSeedId).Scan(&nonce).Error
+	return nonce, err
 }
 
-func (db *DB) RemoveGameState(gameId uint, userId uint, coinId uint) error {
+// FindByIdempotencyKey returns the bet or the game state the user created with the key.
+// Both are nil when the key wasn't used yet.
+func (db *DB) FindByIdempotencyKey(userId uint, key string) (*Bet, *GameState, error) {
+	bet := Bet{}
+	err := db.Where("user_id=? AND idempotency_key=?", userId, key).First(&bet).Error
+	if err == nil {
+		return &bet, nil, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+
 	gameState := GameState{}
-	err := db.Where("game_id=? AND user_id=? AND coin_id=?", gameId, userId, coinId).Delete(&gameState).Error
+	err = db.Where("user_id=? AND idempotency_key=?", userId, key).First(&gameState).Error
+	if err == nil {
+		return nil, &gameState, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
 
-	return err
+	// keys of earlier steps of a stateful game
+	stateKey := StateKey{}
+	err = db.Where("user_id=? AND idempotency_key=?", userId, key).First(&stateKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if stateKey.BetID != nil {
+		err = db.Where("id=?", *stateKey.BetID).First(&bet).Error
+		if err != nil {
+			return nil, nil, err
+		}
+		return &bet, nil, nil
+	}
+	gameState, err = db.GetGameState(stateKey.GameID, userId, stateKey.CoinID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, &gameState, nil
 }
 
-func (db *DB) InsertGameState(gameId uint, userId uint, uuid string, coinId uint, data string, state string, amount decimal.Decimal, userSeedId uint, serverSeedId uint, timestamp time.Time) error {
-	gameState := GameState{
-		Timestamp:    timestamp,
-		Amount:       amount,
-		BetInfo:      data,
-		State:        state,
-		UUID:         uuid,
-		GameID:       gameId,
-		UserID:       userId,
-		CoinID:       coinId,
-		UserSeedID:   userSeedId,
-		ServerSeedID: serverSeedId,
+// GameVersions returns the version of the parameters of every game,
+// a version is created the first time a set of parameters is seen.
+func (db *DB) GameVersions(games []Game) (map[uint]uint, error) {
+	versions := make(map[uint]uint, len(games))
+	for _, game := range games {
+		var id uint
+		err := db.Raw(`INSERT INTO game_versions (game_id, parameters, created_at) VALUES (?, ?, NOW())
+			ON CONFLICT (game_id, md5(parameters)) DO UPDATE SET game_id = EXCLUDED.game_id
+			RETURNING id`, game.ID, game.Parameters).Scan(&id).Error
+		if err != nil {
+			return nil, err
+		}
+		versions[game.ID] = id
 	}
-	err := db.Create(&gameState).Error
+	return versions, nil
+}
 
-	return err
+// GameVersionParameters returns the parameters of a version of the game.
+func (db *DB) GameVersionParameters(gameId uint, versionId uint) (string, error) {
+	version := GameVersion{}
+	err := db.Where("id=? AND game_id=?", versionId, gameId).First(&version).Error
+	return version.Parameters, err
 }
 
 func (db *DB) GetGameState(gameId uint, userId uint, coinId uint) (GameState, error) {
@@ -68,59 +227,67 @@ func (db *DB) GetGameState(gameId uint, userId uint, coinId uint) (GameState, er
 	return gameState, err
 }
 
-func (db *DB) UpdateGameState(gameId uint, userId uint, coinId uint, state string) error {
-	err := db.Where("game_id=? AND user_id=? AND coin_id=?", gameId, userId, coinId).Update("state", &state).Error
+// GetBetLimit returns the limits configured for the game and coin,
+// or the default limits when nothing is configured.
+func (db *DB) GetBetLimit(gameId uint, coin Coin) (BetLimit, error) {
+	limit := BetLimit{}
+	err := db.Where("game_id=? AND coin_id=?", gameId, coin.ID).First(&limit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return BetLimit{
+			GameID:    gameId,
+			CoinID:    coin.ID,
+			MinStake:  decimal.Zero,
+			MaxStake:  DefaultMaxStakeUsd.Mul(coin.Price),
+			MaxGames:  DefaultMaxGames,
+			MaxPayout: decimal.Zero,
+		}, nil
+	}
 
-	return err
+	return limit, err
 }
 
-func (db *DB) IncreaseBalance(userId uint, coinId uint, amount decimal.Decimal) error {
-	err := db.Transaction(func(tx *gorm.DB) error {
-
-		balance := Amount{}
-		err := tx.Where("coin_id = ? AND user_id = ?", coinId, userId).First(&balance).Error
-		if err != nil {
-			return err
-		}
-
-		if err := tx.Model(&Amount{}).Where("user_id=? AND coin_id=?", userId, coinId).Update("amount", balance.Amount.Add(amount)).Error; err != nil {
-			return err
-		}
-
-		return nil
-	})
-	return err
+func (db *DB) SetBetLimit(limit *BetLimit) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "game_id"}, {Name: "coin_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"min_stake", "max_stake", "max_games", "max_payout"}),
+	}).Create(limit).Error
 }
 
-func (db *DB) SubIncBalance(userId uint, coinId uint, subAmount decimal.Decimal, addAmount decimal.Decimal) error {
-	err := db.Transaction(func(tx *gorm.DB) error {
-
-		balance := Amount{}
-		err := tx.Where("coin_id = ? AND user_id = ?", coinId, userId).First(&balance).Error
-		if err != nil {
-			return err
-		}
-
-		if subAmount.GreaterThan(balance.Amount) {
-			return errors.New("Amount is greater, than balance")
-		}
-
-		if err := tx.Model(&Amount{}).Where("user_id=? AND coin_id=?", userId, coinId).Update("amount", balance.Amount.Sub(subAmount)).Error; err != nil {
-			return err
-		}
+// GetBankroll returns the bankroll of the coin, gorm.ErrRecordNotFound means it isn't tracked.
+func (db *DB) GetBankroll(coinId uint) (Bankroll, error) {
+	bankroll := Bankroll{}
+	err := db.Where("coin_id=?", coinId).First(&bankroll).Error
+	return bankroll, err
+}
 
-		err = tx.Where("coin_id = ? AND user_id = ?", coinId, userId).First(&balance).Error
-		if err != nil {
-			return err
-		}
+// TopUpBankroll adds amount to the bankroll of the coin, creating the bankroll if needed.
+// A negative amount withdraws from it.
+func (db *DB) TopUpBankroll(coinId uint, amount decimal.Decimal) (Bankroll, error) {
+	bankroll := Bankroll{}
+	err := db.Raw(`INSERT INTO bankrolls (coin_id, amount) VALUES (?, ?)
+		ON CONFLICT (coin_id) DO UPDATE SET amount = bankrolls.amount + EXCLUDED.amount
+		RETURNING *`, coinId, amount).Scan(&bankroll).Error
+	return bankroll, err
+}
 
-		if err := tx.Model(&Amount{}).Where("user_id=? AND coin_id=?", userId, coinId).Update("amount", balance.Amount.Add(addAmount)).Error; err != nil {
-			return err
-		}
+// SetExposureFraction changes the part of the bankroll a single bet may win, creating the bankroll if needed.
+func (db *DB) SetExposureFraction(coinId uint, fraction decimal.Decimal) (Bankroll, error) {
+	bankroll := Bankroll{}
+	err := db.Raw(`INSERT INTO bankrolls (coin_id, exposure_fraction) VALUES (?, ?)
+		ON CONFLICT (coin_id) DO UPDATE SET exposure_fraction = EXCLUDED.exposure_fraction
+		RETURNING *`, coinId, fraction).Scan(&bankroll).Error
+	return bankroll, err
+}
 
-		return nil
-	})
+// CreateAutoBetSession stores a new session, ErrAutoBetRunning means the user already
+// has an active session on the game and coin, see auto_bet_active_unique_idx.
+func (db *DB) CreateAutoBetSession(session *AutoBetSession) error {
+	err := db.Create(session).Error
 
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "auto_bet_active_unique_idx" {
+		return ErrAutoBetRunning
+	}
 	return err
 }
 
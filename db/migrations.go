@@ -13,7 +13,7 @@ func RunMigrations(db *gorm.DB) {
 	}
 
 	// Automatically migrate the schemas
-	err = db.AutoMigrate(&User{}, &RefreshToken{}, &Coin{}, &Amount{}, &Game{}, &UserSeed{}, &ServerSeed{}, &Bet{}, &Payout{}, &GameState{}, &Referal{}, &ReferalLink{})
+	err = db.AutoMigrate(&User{}, &RefreshToken{}, &Coin{}, &Amount{}, &Game{}, &UserSeed{}, &ServerSeed{}, &Bet{}, &Payout{}, &GameState{}, &Referal{}, &ReferalLink{}, &BetLimit{}, &SeedNonce{}, &StateKey{}, &GameVersion{}, &Bankroll{}, &AutoBetSession{}, &CrashChain{}, &CrashRound{})
 	if err != nil {
 		log.Fatalf("failed to migrate database: %v", err)
 	}
@@ -44,6 +44,68 @@ func RunMigrations(db *gorm.DB) {
 		log.Fatalf("failed to create unique index for game states: %v", err)
 	}
 
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS bet_idempotency_unique_idx ON bets (user_id, idempotency_key) WHERE idempotency_key IS NOT NULL;").Error
+	if err != nil {
+		log.Fatalf("failed to create unique index for bet idempotency keys: %v", err)
+	}
+
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS state_idempotency_unique_idx ON game_states (user_id, idempotency_key) WHERE idempotency_key IS NOT NULL;").Error
+	if err != nil {
+		log.Fatalf("failed to create unique index for game state idempotency keys: %v", err)
+	}
+
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS game_version_unique_idx ON game_versions (game_id, md5(parameters));").Error
+	if err != nil {
+		log.Fatalf("failed to create unique index for game versions: %v", err)
+	}
+
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS state_key_unique_idx ON state_keys (user_id, idempotency_key);").Error
+	if err != nil {
+		log.Fatalf("failed to create unique index for state keys: %v", err)
+	}
+
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS seed_nonce_unique_idx ON seed_nonces (user_seed_id, server_seed_id);").Error
+	if err != nil {
+		log.Fatalf("failed to create unique index for seed nonces: %v", err)
+	}
+
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS bankroll_unique_idx ON bankrolls (coin_id);").Error
+	if err != nil {
+		log.Fatalf("failed to create unique index for bankrolls: %v", err)
+	}
+
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS auto_bet_active_unique_idx ON auto_bet_sessions (user_id, game_id, coin_id) WHERE active;").Error
+	if err != nil {
+		log.Fatalf("failed to create unique index for auto bet sessions: %v", err)
+	}
+
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS crash_round_unique_idx ON crash_rounds (chain_id, position);").Error
+	if err != nil {
+		log.Fatalf("failed to create unique index for crash rounds: %v", err)
+	}
+
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS bet_limit_unique_idx ON bet_limits (game_id, coin_id);").Error
+	if err != nil {
+		log.Fatalf("failed to create unique index for bet limits: %v", err)
+	}
+
+	// Let the running servers know that they have to reload the games
+	err = db.Exec(`
+		CREATE OR REPLACE FUNCTION notify_games_changed() RETURNS trigger AS $$
+		BEGIN
+			PERFORM pg_notify('games_changed', '');
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS games_changed ON games;
+		CREATE TRIGGER games_changed AFTER INSERT OR UPDATE OR DELETE ON games
+			FOR EACH STATEMENT EXECUTE FUNCTION notify_games_changed();
+	`).Error
+	if err != nil {
+		log.Fatalf("failed to create games trigger: %v", err)
+	}
+
 	err = db.Exec("INSERT INTO Coins(name, price) VALUES ('DraxBonus',1000);").Error
 	if err != nil {
 		log.Printf("failed to create unique index for game states: %v", err)
@@ -62,7 +124,7 @@ INSERT INTO Games( name, parameters ) VALUES ( 'Dice', '{"profit_coef":"1.94"}'
 
 INSERT INTO Games( name, parameters ) VALUES ( 'Rocket', '{"profit_coef":"1.94"}' );
 
-INSERT INTO Games( name, parameters ) VALUES ( 'Crash', '{"profit_coef":"1.94"}' );
+INSERT INTO Games( name, parameters ) VALUES ( 'Crash', '{"house_edge":"0.01", "betting_window":10, "max_multiplier":"1000"}' );
 
 INSERT INTO Games( name, parameters ) VALUES ( 'RPS', '{"profit_coef":"1.98", "draw_coef":"0.99"}' );
 
@@ -86,12 +148,27 @@ INSERT INTO Games( name, parameters ) VALUES ( 'Apples', '{ "difficulties": [ {
 
 INSERT INTO Games( name, parameters ) VALUES ( 'Slots', '{ "num_outcomes": 343, "multipliers": ["5", "3", "3", "3", "3", "3", "3", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "2", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "10", "0", "0", "10", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "12", "0", "12", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "20", "20", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "45", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "100"] }' );
 
-INSERT INTO Games( name, parameters ) VALUES ( 'Roulette', '{ "zero_coef":"52", "num_coef":"34.8148", "num2_coef":"17.3752", "num4_coef":"8.6957", "num12_coef":"2.8986", "num18_coef":"1.9322" }' );
+INSERT INTO Games( name, parameters ) VALUES ( 'Roulette', '{ "zero_coef":"34.8148", "num_coef":"34.8148", "num2_coef":"17.3752", "num4_coef":"8.6957", "num12_coef":"2.8986", "num18_coef":"1.9322" }' );
 
-INSERT INTO Games( name, parameters ) VALUES ( 'BigSlots', ' { "tiles": [ { "8": "0.25", "9": "0.25", "10": "0.75", "11": "0.75", "12": "2", "13": "2", "14": "2", "15": "2", "16": "2", "17": "2", "18": "2", "19": "2", "20": "2", "21": "2", "22": "2", "23": "2", "24": "2", "25": "2", "26": "2", "27": "2", "28": "2", "29": "2", "30": "2" }, { "8": "0.40", "9": "0.40", "10": "0.90", "11": "0.90", "12": "4", "13": "4", "14": "4", "15": "4", "16": "4", "17": "4", "18": "4", "19": "4", "20": "4", "21": "4", "22": "4", "23": "4", "24": "4", "25": "4", "26": "4", "27": "4", "28": "4", "29": "4", "30": "4" }, { "8": "0.50", "9": "0.50", "10": "1", "11": "1", "12": "5", "13": "5", "14": "5", "15": "5", "16": "5", "17": "5", "18": "5", "19": "5", "20": "5", "21": "5", "22": "5", "23": "5", "24": "5", "25": "5", "26": "5", "27": "5", "28": "5", "29": "5", "30": "5" }, { "8": "0.80", "9": "0.80", "10": "1.20", "11": "1.20", "12": "8", "13": "8", "14": "8", "15": "8", "16": "8", "17": "8", "18": "8", "19": "8", "20": "8", "21": "8", "22": "8", "23": "8", "24": "8", "25": "8", "26": "8", "27": "8", "28": "8", "29": "8", "30": "8" }, { "8": "1", "9": "1", "10": "1.50", "11": "1.50", "12": "10", "13": "10", "14": "10", "15": "10", "16": "10", "17": "10", "18": "10", "19": "10", "20": "10", "21": "10", "22": "10", "23": "10", "24": "10", "25": "10", "26": "10", "27": "10", "28": "10", "29": "10", "30": "10" }, { "8": "1.5", "9": "1.5", "10": "2", "11": "2", "12": "12", "13": "12", "14": "12", "15": "12", "16": "12", "17": "12", "18": "12", "19": "12", "20": "12", "21": "12", "22": "12", "23": "12", "24": "12", "25": "12", "26": "12", "27": "12", "28": "12", "29": "12", "30": "12" }, { "8": "2", "9": "2", "10": "5", "11": "5", "12": "15", "13": "15", "14": "15", "15": "15", "16": "15", "17": "15", "18": "15", "19": "15", "20": "15", "21": "15", "22": "15", "23": "15", "24": "15", "25": "15", "26": "15", "27": "15", "28": "15", "29": "15", "30": "15" }, { "8": "2.5", "9": "2.5", "10": "10", "11": "10", "12": "25", "13": "25", "14": "25", "15": "25", "16": "25", "17": "25", "18": "25", "19": "25", "20": "25", "21": "25", "22": "25", "23": "25", "24": "25", "25": "25", "26": "25", "27": "25", "28": "25", "29": "25", "30": "25" }, { "8": "10", "9": "10", "10": "25", "11": "25", "12": "50", "13": "50", "14": "50", "15": "50", "16": "50", "17": "50", "18": "50", "19": "50", "20": "50", "21": "50", "22": "50", "23": "50", "24": "50", "25": "50", "26": "50", "27": "50", "28": "50", "29": "50", "30": "50" }, { "4": "3", "5": "5", "6": "100", "11": "100", "12": "100", "13": "100", "14": "100", "15": "100", "16": "100", "17": "100", "18": "100", "19": "100", "20": "100", "21": "100", "22": "100", "23": "100", "24": "100", "25": "100", "26": "100", "27": "100", "28": "100", "29": "100", "30": "100" } ], "multipliers": [ "2", "8", "15", "25", "100" ], "multiplier_chance": 10, "free_spins_prices": { "1": 200000, "2": 200 }, "free_spins_reward_amount": 15 } ' );
+INSERT INTO Games( name, parameters ) VALUES ( 'BigSlots', ' { "tiles": [ { "8": "0.25", "9": "0.25", "10": "0.75", "11": "0.75", "12": "2", "13": "2", "14": "2", "15": "2", "16": "2", "17": "2", "18": "2", "19": "2", "20": "2", "21": "2", "22": "2", "23": "2", "24": "2", "25": "2", "26": "2", "27": "2", "28": "2", "29": "2", "30": "2" }, { "8": "0.40", "9": "0.40", "10": "0.90", "11": "0.90", "12": "4", "13": "4", "14": "4", "15": "4", "16": "4", "17": "4", "18": "4", "19": "4", "20": "4", "21": "4", "22": "4", "23": "4", "24": "4", "25": "4", "26": "4", "27": "4", "28": "4", "29": "4", "30": "4" }, { "8": "0.50", "9": "0.50", "10": "1", "11": "1", "12": "5", "13": "5", "14": "5", "15": "5", "16": "5", "17": "5", "18": "5", "19": "5", "20": "5", "21": "5", "22": "5", "23": "5", "24": "5", "25": "5", "26": "5", "27": "5", "28": "5", "29": "5", "30": "5" }, { "8": "0.80", "9": "0.80", "10": "1.20", "11": "1.20", "12": "8", "13": "8", "14": "8", "15": "8", "16": "8", "17": "8", "18": "8", "19": "8", "20": "8", "21": "8", "22": "8", "23": "8", "24": "8", "25": "8", "26": "8", "27": "8", "28": "8", "29": "8", "30": "8" }, { "8": "1", "9": "1", "10": "1.50", "11": "1.50", "12": "10", "13": "10", "14": "10", "15": "10", "16": "10", "17": "10", "18": "10", "19": "10", "20": "10", "21": "10", "22": "10", "23": "10", "24": "10", "25": "10", "26": "10", "27": "10", "28": "10", "29": "10", "30": "10" }, { "8": "1.5", "9": "1.5", "10": "2", "11": "2", "12": "12", "13": "12", "14": "12", "15": "12", "16": "12", "17": "12", "18": "12", "19": "12", "20": "12", "21": "12", "22": "12", "23": "12", "24": "12", "25": "12", "26": "12", "27": "12", "28": "12", "29": "12", "30": "12" }, { "8": "2", "9": "2", "10": "5", "11": "5", "12": "15", "13": "15", "14": "15", "15": "15", "16": "15", "17": "15", "18": "15", "19": "15", "20": "15", "21": "15", "22": "15", "23": "15", "24": "15", "25": "15", "26": "15", "27": "15", "28": "15", "29": "15", "30": "15" }, { "8": "2.5", "9": "2.5", "10": "10", "11": "10", "12": "25", "13": "25", "14": "25", "15": "25", "16": "25", "17": "25", "18": "25", "19": "25", "20": "25", "21": "25", "22": "25", "23": "25", "24": "25", "25": "25", "26": "25", "27": "25", "28": "25", "29": "25", "30": "25" }, { "8": "10", "9": "10", "10": "25", "11": "25", "12": "50", "13": "50", "14": "50", "15": "50", "16": "50", "17": "50", "18": "50", "19": "50", "20": "50", "21": "50", "22": "50", "23": "50", "24": "50", "25": "50", "26": "50", "27": "50", "28": "50", "29": "50", "30": "50" }, { "4": "3", "5": "5", "6": "100", "11": "100", "12": "100", "13": "100", "14": "100", "15": "100", "16": "100", "17": "100", "18": "100", "19": "100", "20": "100", "21": "100", "22": "100", "23": "100", "24": "100", "25": "100", "26": "100", "27": "100", "28": "100", "29": "100", "30": "100" } ], "multipliers": [ "2", "8", "15", "25", "100" ], "multiplier_chance": 10, "free_spins_prices": { "1": 20000, "2": 200 }, "free_spins_reward_amount": 15 } ' );
 	`).Error
 	if err != nil {
 		log.Printf("failed to create unique index for game states: %v", err)
 	}
 
+	// games added or changed after the first release, the block above fails as a whole once the games are seeded.
+	// Crash, Roulette and BigSlots keep parameters an admin changed, only the ones of the first seed are replaced,
+	// the first BigSlots free spins price of DraxBonus was above the default max stake of the coin
+	err = db.Exec(`
+INSERT INTO Games( name, parameters ) VALUES ( 'Crash', '{"house_edge":"0.01", "betting_window":10, "max_multiplier":"1000"}' ) ON CONFLICT (name) DO NOTHING;
+UPDATE Games SET parameters = '{"house_edge":"0.01", "betting_window":10, "max_multiplier":"1000"}' WHERE name = 'Crash' AND parameters = '{"profit_coef":"1.94"}';
+INSERT INTO Games( name, parameters ) VALUES ( 'Blackjack', '{"decks":6, "blackjack_payout":"1.5", "dealer_hits_soft17":false, "max_hands":4}' ) ON CONFLICT (name) DO NOTHING;
+INSERT INTO Games( name, parameters ) VALUES ( 'HiLo', '{"edge":"0.01", "max_win":"1000"}' ) ON CONFLICT (name) DO NOTHING;
+UPDATE Games SET parameters = '{ "zero_coef":"34.8148", "num_coef":"34.8148", "num2_coef":"17.3752", "num4_coef":"8.6957", "num12_coef":"2.8986", "num18_coef":"1.9322" }' WHERE name = 'Roulette' AND parameters = '{ "zero_coef":"52", "num_coef":"34.8148", "num2_coef":"17.3752", "num4_coef":"8.6957", "num12_coef":"2.8986", "num18_coef":"1.9322" }';
+UPDATE Games SET parameters = jsonb_set(parameters::jsonb, '{free_spins_prices,1}', '20000')::text WHERE name = 'BigSlots' AND parameters::jsonb #>> '{free_spins_prices,1}' = '200000';
+	`).Error
+	if err != nil {
+		log.Printf("failed to add new games: %v", err)
+	}
+
 }
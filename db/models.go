@@ -1,6 +1,7 @@
 package db
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -79,6 +80,11 @@ type Bet struct {
 	BetInfo   string          `gorm:"not null"`
 	State     string
 	UUID      string `gorm:"not null"`
+	// Nonce of the last step, Steps holds every step of the bet as a JSON list of BetStep
+	Nonce uint64 `gorm:"not null;default:0"`
+	Steps string
+	// IdempotencyKey is unique per user, see bet_idempotency_unique_idx
+	IdempotencyKey *string `gorm:"size:64"`
 
 	GameID       uint `gorm:"not null"`
 	Game         Game `gorm:"not null;constraint:OnDelete:CASCADE"`
@@ -92,6 +98,58 @@ type Bet struct {
 	ServerSeed   Coin `gorm:"not null;constraint:OnDelete:CASCADE"`
 }
 
+// BetStep is a single call into a game made while playing a bet.
+// The random numbers of the step are generated from the seeds and the nonce,
+// Request is the requests.Bet or, for continue steps, the requests.ContinueGame.
+type BetStep struct {
+	Nonce        uint64          `json:"nonce"`
+	UserSeedID   uint            `json:"user_seed_id"`
+	ServerSeedID uint            `json:"server_seed_id"`
+	Continue     bool            `json:"continue"`
+	Request      json.RawMessage `json:"request"`
+	// Resolve is set on the last step of a game that expired and was finished by the server
+	Resolve bool `json:"resolve,omitempty"`
+	// GameVersionID is the version of the parameters the step was played with,
+	// MaxPayout the cap of the bet limit applied to its result
+	GameVersionID uint             `json:"game_version_id,omitempty"`
+	MaxPayout     *decimal.Decimal `json:"max_payout,omitempty"`
+}
+
+// SeedNonce counts the bets played with a pair of seeds, see NextNonce.
+type SeedNonce struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserSeedID   uint   `gorm:"not null"`
+	ServerSeedID uint   `gorm:"not null"`
+	Nonce        uint64 `gorm:"not null;default:0"`
+}
+
+// GameVersion is a set of parameters a game was played with,
+// bet steps point to it so they can be replayed after the parameters changed.
+type GameVersion struct {
+	ID         uint      `gorm:"primaryKey"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	Parameters string    `gorm:"not null"`
+
+	GameID uint `gorm:"not null"`
+	Game   Game `gorm:"not null;constraint:OnDelete:CASCADE"`
+}
+
+// StateKey is the idempotency key of a step of a stateful game. The game state is replaced
+// on every step, so the keys are kept here and point to the bet once the game is settled.
+type StateKey struct {
+	ID             uint      `gorm:"primaryKey"`
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	IdempotencyKey string    `gorm:"size:64;not null"`
+	BetID          *uint
+
+	UserID uint `gorm:"not null"`
+	User   User `gorm:"not null;constraint:OnDelete:CASCADE"`
+	GameID uint `gorm:"not null"`
+	Game   Game `gorm:"not null;constraint:OnDelete:CASCADE"`
+	CoinID uint `gorm:"not null"`
+	Coin   Coin `gorm:"not null;constraint:OnDelete:CASCADE"`
+}
+
 type Payout struct {
 	ID             uint            `gorm:"primaryKey"`
 	Timestamp      time.Time       `gorm:"autoCreateTime"`
@@ -104,11 +162,14 @@ type Payout struct {
 
 type GameResult struct {
 	TotalProfit decimal.Decimal
-	Outcomes    []uint64
-	Profits     []decimal.Decimal
-	NumGames    uint32
-	Data        string
-	Finished    bool
+	// Debit is stake taken on top of the bet amount by a continue step, like a double or a split.
+	// It's added to the amount of the game state and of the bet.
+	Debit    decimal.Decimal
+	Outcomes []uint64
+	Profits  []decimal.Decimal
+	NumGames uint32
+	Data     string
+	Finished bool
 }
 
 type GameState struct {
@@ -118,6 +179,11 @@ type GameState struct {
 	BetInfo   string          `gorm:"not null" json:"bet_info"`
 	State     string          `gorm:"not null" json:"state"`
 	UUID      string          `gorm:"not null" json:"uuid"`
+	// Nonce of the last step, Steps holds the steps played so far as a JSON list of BetStep
+	Nonce uint64 `gorm:"not null;default:0" json:"nonce"`
+	Steps string `json:"steps"`
+	// IdempotencyKey is unique per user, see state_idempotency_unique_idx
+	IdempotencyKey *string `gorm:"size:64" json:"idempotency_key"`
 
 	GameID       uint `gorm:"not null" json:"game_id"`
 	Game         Game `gorm:"not null;constraint:OnDelete:CASCADE" json:"-"`
@@ -131,6 +197,85 @@ type GameState struct {
 	ServerSeed   Coin `gorm:"not null;constraint:OnDelete:CASCADE" json:"-"`
 }
 
+// BetLimit restricts the bets placed on a game with a coin.
+// MinStake applies to the amount of a single game, MaxStake to the whole bet
+// (amount times num_games). A zero MaxPayout means payouts are not capped.
+type BetLimit struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	GameID    uint            `gorm:"not null" json:"game_id"`
+	Game      Game            `gorm:"not null;constraint:OnDelete:CASCADE" json:"-"`
+	CoinID    uint            `gorm:"not null" json:"coin_id"`
+	Coin      Coin            `gorm:"not null;constraint:OnDelete:CASCADE" json:"-"`
+	MinStake  decimal.Decimal `gorm:"type:numeric(1000,4);default:0" json:"min_stake"`
+	MaxStake  decimal.Decimal `gorm:"type:numeric(1000,4);not null" json:"max_stake"`
+	MaxGames  uint64          `gorm:"not null" json:"max_games"`
+	MaxPayout decimal.Decimal `gorm:"type:numeric(1000,4);default:0" json:"max_payout"`
+}
+
+// Bankroll is what the house holds in a coin, settlements move the stakes and payouts in and out of it.
+// A single bet may not be able to win more than ExposureFraction of the amount,
+// a zero ExposureFraction disables the check.
+type Bankroll struct {
+	ID               uint            `gorm:"primaryKey" json:"id"`
+	CoinID           uint            `gorm:"not null" json:"coin_id"`
+	Coin             Coin            `gorm:"not null;constraint:OnDelete:CASCADE" json:"-"`
+	Amount           decimal.Decimal `gorm:"type:numeric(1000,4);default:0" json:"amount"`
+	ExposureFraction decimal.Decimal `gorm:"type:numeric(1000,4);default:0" json:"exposure_fraction"`
+}
+
+// AutoBetSession is a series of bets the server places for the user until it's stopped.
+// Strategy holds the requests.AutoBetStrategy as JSON, Amount and Data are what the next round uses.
+type AutoBetSession struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	BaseAmount decimal.Decimal `gorm:"type:numeric(1000,4);not null" json:"base_amount"`
+	BaseData   string          `gorm:"not null" json:"base_data"`
+	Amount     decimal.Decimal `gorm:"type:numeric(1000,4);not null" json:"amount"`
+	Data       string          `gorm:"not null" json:"data"`
+	Strategy   string          `gorm:"not null" json:"strategy"`
+	Rounds     uint64          `gorm:"not null;default:0" json:"rounds"`
+	Losses     uint64          `gorm:"not null;default:0" json:"losses"`
+	Profit     decimal.Decimal `gorm:"type:numeric(1000,4);default:0" json:"profit"`
+	Active     bool            `gorm:"not null" json:"active"`
+	StopReason string          `json:"stop_reason"`
+	// UUID of the websocket that gets the updates, replaced when the user reconnects
+	UUID string `gorm:"not null" json:"-"`
+
+	GameID uint `gorm:"not null" json:"game_id"`
+	Game   Game `gorm:"not null;constraint:OnDelete:CASCADE" json:"-"`
+	UserID uint `gorm:"not null" json:"user_id"`
+	User   User `gorm:"not null;constraint:OnDelete:CASCADE" json:"-"`
+	CoinID uint `gorm:"not null" json:"coin_id"`
+	Coin   Coin `gorm:"not null;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// CrashChain is a pre-committed chain of hashes the Crash rounds are played with.
+// Commitment is published before the first round, the seed stays secret.
+type CrashChain struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	Seed       string    `gorm:"not null" json:"-"`
+	Length     uint64    `gorm:"not null" json:"length"`
+	Commitment string    `gorm:"not null" json:"commitment"`
+	Exhausted  bool      `gorm:"not null;default:false" json:"exhausted"`
+}
+
+// CrashRound is a single round of Crash, Hash is the hash of the chain at Position.
+// Hash and CrashPoint must not be shown before the round is Finished.
+type CrashRound struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	Position   uint64          `gorm:"not null" json:"position"`
+	Hash       string          `gorm:"not null" json:"hash"`
+	CrashPoint decimal.Decimal `gorm:"type:numeric(1000,4);not null" json:"crash_point"`
+	Finished   bool            `gorm:"not null;default:false" json:"finished"`
+	// Cancelled rounds were stopped by a shutdown and their bets were refunded
+	Cancelled bool `gorm:"not null;default:false" json:"cancelled"`
+
+	ChainID uint       `gorm:"not null" json:"chain_id"`
+	Chain   CrashChain `gorm:"not null;constraint:OnDelete:CASCADE" json:"-"`
+}
+
 type ReferalLink struct {
 	ID       uint   `gorm:"primaryKey;autoIncrement"`
 	ReferTo  uint   `gorm:"not null;unique;constraint:OnDelete:CASCADE;references:User(ID)"`
@@ -0,0 +1,316 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/communications"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+	"greekkeepers.io/backend/responses"
+)
+
+// AutoBetInterval is the pause between two rounds of an auto bet session.
+const AutoBetInterval = 500 * time.Millisecond
+
+var (
+	errAutoBetStopped = errors.New("Auto bet session is stopped")
+	errAutoBetGame    = rejectBet(responses.UnknownGame, "Auto bet is only available for stateless games")
+	errAutoBetRunning = rejectBet(responses.BadBetData, "Auto bet is already running for this game and coin")
+	errAutoBetMissing = rejectBet(responses.BadBetData, "Auto bet session not found")
+)
+
+// AutoBetRound plays the next round of an auto bet session.
+// It's submitted to the pool so it's settled in order with the other bets of the user,
+// the result of the round is sent to Done.
+type AutoBetRound struct {
+	SessionID uint
+	UserID    uint
+	GameID    uint
+	Done      chan error
+}
+
+// AutoBets runs a goroutine for every active auto bet session.
+// Sessions are stored in the database, so they outlive the websocket and the server.
+type AutoBets struct {
+	pool *Pool
+	ctx  context.Context
+
+	mu      sync.Mutex
+	running map[uint]bool
+}
+
+func NewAutoBets(ctx context.Context, pool *Pool) *AutoBets {
+	return &AutoBets{
+		pool:    pool,
+		ctx:     ctx,
+		running: make(map[uint]bool),
+	}
+}
+
+// Resume starts playing the sessions that were active when the server stopped.
+func (a *AutoBets) Resume() error {
+	var sessions []db.AutoBetSession
+	if err := a.pool.Stateless.Db.Where("active").Find(&sessions).Error; err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		a.run(session)
+	}
+	slog.Info("Auto bet sessions resumed", "sessions", len(sessions))
+	return nil
+}
+
+// Start stores a new session and starts playing it.
+func (a *AutoBets) Start(start requests.StartAutoBet) (db.AutoBetSession, error) {
+	if _, ok := a.pool.Stateless.Games()[start.GameID]; !ok {
+		return db.AutoBetSession{}, errAutoBetGame
+	}
+	if !start.Amount.IsPositive() {
+		return db.AutoBetSession{}, errStakeTooLow
+	}
+
+	strategy, err := json.Marshal(start.Strategy)
+	if err != nil {
+		return db.AutoBetSession{}, err
+	}
+
+	session := db.AutoBetSession{
+		BaseAmount: start.Amount,
+		BaseData:   start.Data,
+		Amount:     start.Amount,
+		Data:       start.Data,
+		Strategy:   string(strategy),
+		Active:     true,
+		UUID:       start.UUID,
+		GameID:     start.GameID,
+		UserID:     start.UserID,
+		CoinID:     start.CoinID,
+	}
+	err = a.pool.Stateless.Db.CreateAutoBetSession(&session)
+	if errors.Is(err, db.ErrAutoBetRunning) {
+		return db.AutoBetSession{}, errAutoBetRunning
+	}
+	if err != nil {
+		slog.Error("Error creating auto bet session", "start", start, "err", err)
+		return db.AutoBetSession{}, errInternal
+	}
+
+	a.run(session)
+	return session, nil
+}
+
+// Stop stops a session of the user, a round that is already queued still gets played.
+func (a *AutoBets) Stop(userId uint, sessionId uint) (db.AutoBetSession, error) {
+	session := db.AutoBetSession{}
+	err := a.pool.Stateless.Db.Where("id = ? AND user_id = ?", sessionId, userId).First(&session).Error
+	if err != nil {
+		return db.AutoBetSession{}, errAutoBetMissing
+	}
+
+	a.pool.Stateless.stopAutoBet(&session, "Stopped by user")
+	return session, nil
+}
+
+// Attach sends the updates of the active sessions of the user to the websocket and returns them.
+func (a *AutoBets) Attach(userId uint, uuid string) ([]db.AutoBetSession, error) {
+	var sessions []db.AutoBetSession
+	Db := a.pool.Stateless.Db
+	if err := Db.Model(&db.AutoBetSession{}).Where("user_id = ? AND active", userId).Update("uuid", uuid).Error; err != nil {
+		return nil, err
+	}
+	if err := Db.Where("user_id = ? AND active", userId).Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (a *AutoBets) run(session db.AutoBetSession) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.running[session.ID] {
+		return
+	}
+	a.running[session.ID] = true
+
+	go func() {
+		defer func() {
+			a.mu.Lock()
+			delete(a.running, session.ID)
+			a.mu.Unlock()
+		}()
+
+		for {
+			done := make(chan error, 1)
+			a.pool.Submit(Bet{Bet: AutoBetRound{
+				SessionID: session.ID,
+				UserID:    session.UserID,
+				GameID:    session.GameID,
+				Done:      done,
+			}})
+
+			select {
+			case err := <-done:
+				if err != nil {
+					return
+				}
+			case <-a.ctx.Done():
+				return
+			}
+
+			select {
+			case <-time.After(AutoBetInterval):
+			case <-a.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// autoBetRound plays and settles a single round, then applies the strategy to the session.
+func (e *StatelessEngine) autoBetRound(round AutoBetRound) error {
+	session := db.AutoBetSession{}
+	if err := e.Db.Where("id = ?", round.SessionID).First(&session).Error; err != nil || !session.Active {
+		return errAutoBetStopped
+	}
+
+	strategy := requests.AutoBetStrategy{}
+	if err := json.Unmarshal([]byte(session.Strategy), &strategy); err != nil {
+		slog.Error("Error parsing auto bet strategy", "session", session, "err", err)
+		e.stopAutoBet(&session, "Bad strategy")
+		return errAutoBetStopped
+	}
+
+	dbBet, err := e.play(Bet{Bet: requests.Bet{
+		Amount:   session.Amount,
+		NumGames: 1,
+		UUID:     session.UUID,
+		Data:     session.Data,
+		GameID:   session.GameID,
+		UserID:   session.UserID,
+		CoinID:   session.CoinID,
+	}})
+	if err != nil {
+		var betErr *BetError
+		if !errors.As(err, &betErr) {
+			betErr = errInternal
+		}
+		e.stopAutoBet(&session, betErr.Message)
+		return errAutoBetStopped
+	}
+
+	session.Rounds++
+	session.Profit = session.Profit.Add(dbBet.Profit).Sub(dbBet.Amount)
+	applyStrategy(&session, strategy, dbBet.Profit.GreaterThan(dbBet.Amount))
+
+	err = e.Db.Model(&db.AutoBetSession{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+		"amount": session.Amount,
+		"data":   session.Data,
+		"rounds": session.Rounds,
+		"losses": session.Losses,
+		"profit": session.Profit,
+	}).Error
+	if err != nil {
+		slog.Error("Error updating auto bet session", "session", session, "err", err)
+		e.stopAutoBet(&session, "Internal error")
+		return errAutoBetStopped
+	}
+
+	if reason := e.autoBetStopReason(session, strategy); reason != "" {
+		e.stopAutoBet(&session, reason)
+		return errAutoBetStopped
+	}
+
+	e.notifyAutoBet(session, dbBet.ID)
+	return nil
+}
+
+// applyStrategy picks the stake and the data of the next round.
+// The loss streak data only lasts until the next win.
+func applyStrategy(session *db.AutoBetSession, strategy requests.AutoBetStrategy, won bool) {
+	hundred := decimal.NewFromInt(100)
+	if won {
+		session.Losses = 0
+		if strategy.LossStreakData != "" {
+			session.Data = session.BaseData
+		}
+		if strategy.ResetOnWin {
+			session.Amount = session.BaseAmount
+			session.Data = session.BaseData
+		} else if strategy.OnWinIncrease.IsPositive() {
+			session.Amount = session.Amount.Add(session.Amount.Mul(strategy.OnWinIncrease).Div(hundred))
+		}
+		return
+	}
+
+	session.Losses++
+	if strategy.OnLossIncrease.IsPositive() {
+		session.Amount = session.Amount.Add(session.Amount.Mul(strategy.OnLossIncrease).Div(hundred))
+	}
+	if strategy.LossStreak > 0 && session.Losses >= strategy.LossStreak && strategy.LossStreakData != "" {
+		session.Data = strategy.LossStreakData
+	}
+}
+
+func (e *StatelessEngine) autoBetStopReason(session db.AutoBetSession, strategy requests.AutoBetStrategy) string {
+	if strategy.MaxRounds > 0 && session.Rounds >= strategy.MaxRounds {
+		return "Max rounds reached"
+	}
+	if strategy.StopBalanceBelow.IsZero() && strategy.StopBalanceAbove.IsZero() {
+		return ""
+	}
+
+	balance := db.Amount{}
+	err := e.Db.Where("coin_id = ? AND user_id = ?", session.CoinID, session.UserID).First(&balance).Error
+	if err != nil {
+		slog.Error("Error getting user balance", "session", session, "err", err)
+		return "Internal error"
+	}
+	if !strategy.StopBalanceBelow.IsZero() && balance.Amount.LessThan(strategy.StopBalanceBelow) {
+		return "Balance fell below the threshold"
+	}
+	if !strategy.StopBalanceAbove.IsZero() && balance.Amount.GreaterThan(strategy.StopBalanceAbove) {
+		return "Balance rose above the threshold"
+	}
+	return ""
+}
+
+func (e *StatelessEngine) stopAutoBet(session *db.AutoBetSession, reason string) {
+	err := e.Db.Model(&db.AutoBetSession{}).Where("id = ? AND active", session.ID).Updates(map[string]interface{}{
+		"active":      false,
+		"stop_reason": reason,
+	}).Error
+	if err != nil {
+		slog.Error("Error stopping auto bet session", "session", session, "err", err)
+	}
+	slog.Info("Auto bet session stopped", "session", session.ID, "reason", reason)
+
+	session.Active = false
+	session.StopReason = reason
+	e.notifyAutoBet(*session, 0)
+}
+
+// notifyAutoBet sends the state of the session to the websocket that last attached to it.
+func (e *StatelessEngine) notifyAutoBet(session db.AutoBetSession, betId uint) {
+	e.Manager.Post(communications.ManagerEvent{
+		Type: communications.ReplyFeed,
+		Body: communications.ManagerEventReply{
+			Id: session.UUID,
+			Data: responses.AutoBetUpdate{
+				SessionID:  session.ID,
+				BetID:      betId,
+				Rounds:     session.Rounds,
+				Amount:     session.Amount,
+				Data:       session.Data,
+				Profit:     session.Profit,
+				Active:     session.Active,
+				StopReason: session.StopReason,
+			},
+		},
+	})
+}
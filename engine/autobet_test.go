@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+)
+
+func TestApplyStrategy(t *testing.T) {
+	base := `{"target":50}`
+	streak := `{"target":20}`
+	tests := []struct {
+		name     string
+		session  db.AutoBetSession
+		strategy requests.AutoBetStrategy
+		rounds   []bool
+		amount   decimal.Decimal
+		data     string
+		losses   uint64
+	}{
+		{
+			name:     "loss raises the stake",
+			session:  db.AutoBetSession{Amount: decimal.NewFromInt(10), Data: base},
+			strategy: requests.AutoBetStrategy{OnLossIncrease: decimal.NewFromInt(100)},
+			rounds:   []bool{false, false},
+			amount:   decimal.NewFromInt(40),
+			data:     base,
+			losses:   2,
+		},
+		{
+			name:     "win raises the stake",
+			session:  db.AutoBetSession{Amount: decimal.NewFromInt(10), Data: base},
+			strategy: requests.AutoBetStrategy{OnWinIncrease: decimal.NewFromInt(50)},
+			rounds:   []bool{true},
+			amount:   decimal.NewFromInt(15),
+			data:     base,
+		},
+		{
+			name:     "reset on win goes back to the base stake",
+			session:  db.AutoBetSession{BaseAmount: decimal.NewFromInt(10), Amount: decimal.NewFromInt(10), BaseData: base, Data: base},
+			strategy: requests.AutoBetStrategy{OnLossIncrease: decimal.NewFromInt(100), ResetOnWin: true},
+			rounds:   []bool{false, false, true},
+			amount:   decimal.NewFromInt(10),
+			data:     base,
+		},
+		{
+			name:     "loss streak switches the data",
+			session:  db.AutoBetSession{Amount: decimal.NewFromInt(10), BaseData: base, Data: base},
+			strategy: requests.AutoBetStrategy{LossStreak: 2, LossStreakData: streak},
+			rounds:   []bool{false, false},
+			amount:   decimal.NewFromInt(10),
+			data:     streak,
+			losses:   2,
+		},
+		{
+			name:     "loss streak shorter than the strategy keeps the data",
+			session:  db.AutoBetSession{Amount: decimal.NewFromInt(10), BaseData: base, Data: base},
+			strategy: requests.AutoBetStrategy{LossStreak: 3, LossStreakData: streak},
+			rounds:   []bool{false, false, true, false},
+			amount:   decimal.NewFromInt(10),
+			data:     base,
+			losses:   1,
+		},
+		{
+			name:     "win after a loss streak restores the data",
+			session:  db.AutoBetSession{Amount: decimal.NewFromInt(10), BaseData: base, Data: base},
+			strategy: requests.AutoBetStrategy{OnWinIncrease: decimal.NewFromInt(10), LossStreak: 1, LossStreakData: streak},
+			rounds:   []bool{false, true},
+			amount:   decimal.NewFromInt(11),
+			data:     base,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := tt.session
+			for _, won := range tt.rounds {
+				applyStrategy(&session, tt.strategy, won)
+			}
+			if !session.Amount.Equal(tt.amount) {
+				t.Errorf("amount = %s, want %s", session.Amount, tt.amount)
+			}
+			if session.Data != tt.data {
+				t.Errorf("data = %s, want %s", session.Data, tt.data)
+			}
+			if session.Losses != tt.losses {
+				t.Errorf("losses = %d, want %d", session.Losses, tt.losses)
+			}
+		})
+	}
+}
@@ -0,0 +1,741 @@
+package engine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"greekkeepers.io/backend/communications"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/games"
+	"greekkeepers.io/backend/requests"
+	"greekkeepers.io/backend/responses"
+)
+
+const (
+	// CrashTick is how often a running round is advanced and broadcast.
+	CrashTick = 100 * time.Millisecond
+	// CrashPause is the time between a crash and the next betting window.
+	CrashPause = 3 * time.Second
+	// CrashChainLength is the amount of rounds a new hash chain is made for.
+	CrashChainLength = 100_000
+)
+
+var (
+	errRoundClosed     = rejectBet(responses.RoundClosed, "The round isn't taking bets")
+	errCashoutTooLate  = rejectBet(responses.RoundClosed, "The round already crashed")
+	errAlreadyInRound  = rejectBet(responses.BadBetData, "There is already a bet in this round")
+	errNoCrashBet      = rejectBet(responses.BadBetData, "There is no bet to cash out")
+	errBadAutoCashout  = rejectBet(responses.BadBetData, "Auto cashout must be above 1")
+	errCrashNotRunning = errors.New("Crash round isn't running")
+)
+
+// CrashEngine runs the shared rounds of Crash. A round takes bets during the betting window,
+// then its multiplier rises until it reaches the crash point, bets that weren't cashed out by then are lost.
+// Stakes are taken when the bet is placed and the bet is written once it's cashed out or lost.
+// The lock only guards the round, it isn't held while the database or the manager is used.
+type CrashEngine struct {
+	Db      *db.DB
+	Manager *communications.Manager
+	Hooks   *Hooks
+
+	gameId uint
+
+	mu    sync.Mutex
+	round *crashRound
+}
+
+type crashRound struct {
+	db.CrashRound
+	params      games.CrashParams
+	phase       responses.CrashPhase
+	bettingEnds time.Time
+	startedAt   time.Time
+	bets        map[uint]*crashBet
+	// cashouts since the last broadcast
+	cashouts []responses.CrashCashout
+
+	// closing is set once the betting window is over, placing tracks the stakes still being taken
+	// and settling the bets still being written, both are waited for without the lock
+	closing  bool
+	placing  sync.WaitGroup
+	settling sync.WaitGroup
+}
+
+type crashBet struct {
+	request requests.CrashBet
+	seeds   stepSeeds
+	limit   db.BetLimit
+	settled bool
+	// settling is set while the bet is written outside the lock
+	settling bool
+}
+
+// crashBetState is stored as the State of a Crash bet, Cashout is zero for lost bets
+// and for the game state that holds the bet until it's settled.
+type crashBetState struct {
+	RoundID uint            `json:"round_id"`
+	Cashout decimal.Decimal `json:"cashout"`
+}
+
+func NewCrashEngine(manager *communications.Manager, Db *db.DB, hooks *Hooks) (*CrashEngine, error) {
+	game := db.Game{}
+	if err := Db.Where("name = ?", games.CrashName).First(&game).Error; err != nil {
+		return nil, err
+	}
+	return &CrashEngine{
+		Db:      Db,
+		Manager: manager,
+		Hooks:   hooks,
+		gameId:  game.ID,
+	}, nil
+}
+
+// Run plays rounds until ctx is done, the round in progress is then cancelled and its stakes refunded.
+func (e *CrashEngine) Run(ctx context.Context) {
+	slog.Info("Starting crash engine")
+	for {
+		window, err := e.openRound()
+		if err != nil {
+			slog.Error("Error starting crash round", "err", err)
+			if !sleep(ctx, CrashPause) {
+				return
+			}
+			continue
+		}
+		if !sleep(ctx, window) {
+			e.cancelRound()
+			return
+		}
+
+		e.launch()
+		ticker := time.NewTicker(CrashTick)
+		for crashed := false; !crashed; {
+			select {
+			case <-ticker.C:
+				crashed = e.tick()
+			case <-ctx.Done():
+				ticker.Stop()
+				e.cancelRound()
+				return
+			}
+		}
+		ticker.Stop()
+
+		if !sleep(ctx, CrashPause) {
+			return
+		}
+	}
+}
+
+func sleep(ctx context.Context, duration time.Duration) bool {
+	select {
+	case <-time.After(duration):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Current returns the state of the round in progress.
+func (e *CrashEngine) Current() (responses.CrashUpdate, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.round == nil {
+		return responses.CrashUpdate{}, errCrashNotRunning
+	}
+	multiplier := decimal.NewFromInt(1)
+	switch e.round.phase {
+	case responses.CrashRunning:
+		multiplier = games.CrashMultiplier(time.Since(e.round.startedAt))
+	case responses.CrashCrashed:
+		multiplier = e.round.CrashPoint
+	}
+	return e.round.update(multiplier), nil
+}
+
+// Bet takes the stake of a bet on the round that is taking bets, a user has one bet per round.
+// The bet is kept as a game state until it's settled, so Resume can settle it if the server stops.
+func (e *CrashEngine) Bet(bet requests.CrashBet) error {
+	if !bet.AutoCashout.IsZero() && bet.AutoCashout.LessThanOrEqual(decimal.NewFromInt(1)) {
+		return errBadAutoCashout
+	}
+
+	e.mu.Lock()
+	round := e.round
+	if round == nil || round.phase != responses.CrashBetting || round.closing || time.Now().After(round.bettingEnds) {
+		e.mu.Unlock()
+		return errRoundClosed
+	}
+	if _, ok := round.bets[bet.UserID]; ok {
+		e.mu.Unlock()
+		return errAlreadyInRound
+	}
+	// the seat is held while the stake is taken, launch waits for it
+	seat := &crashBet{request: bet}
+	round.bets[bet.UserID] = seat
+	round.placing.Add(1)
+	e.mu.Unlock()
+
+	err := e.place(round, seat)
+
+	e.mu.Lock()
+	if err != nil {
+		delete(round.bets, bet.UserID)
+	}
+	e.mu.Unlock()
+	round.placing.Done()
+	return err
+}
+
+// place takes the stake of the bet and stores it as a game state.
+func (e *CrashEngine) place(round *crashRound, seat *crashBet) error {
+	bet := seat.request
+	limit, betErr := e.betLimit(bet.CoinID)
+	if betErr != nil {
+		return betErr
+	}
+
+	betInfo, err := json.Marshal(bet)
+	if err != nil {
+		slog.Error("Error marshaling bet", "bet", bet, "err", err)
+		return errInternal
+	}
+
+	request := requests.Bet{
+		Amount:   bet.Amount,
+		NumGames: 1,
+		Data:     string(betInfo),
+		UUID:     bet.UUID,
+		GameID:   e.gameId,
+		CoinID:   bet.CoinID,
+		UserID:   bet.UserID,
+	}
+	if betErr := checkLimits(request, limit); betErr != nil {
+		return betErr
+	}
+	// the bet can win up to its auto cashout, or the max multiplier without one
+	if betErr := checkExposure(e.Db, crashBounds{round.params}, request, bet.Amount); betErr != nil {
+		return betErr
+	}
+	if betErr := e.Hooks.beforeAccept(request); betErr != nil {
+		return betErr
+	}
+
+	// nothing is drawn from the seeds, they are only recorded on the bet
+	seeds, betErr := currentSeeds(e.Db, bet.UserID)
+	if betErr != nil {
+		return betErr
+	}
+
+	pending, err := json.Marshal(crashBetState{RoundID: round.ID})
+	if err != nil {
+		slog.Error("Error marshaling crash state", "bet", bet, "err", err)
+		return errInternal
+	}
+
+	err = e.Db.Settle(db.Settlement{
+		UserID: bet.UserID,
+		CoinID: bet.CoinID,
+		GameID: e.gameId,
+		Debit:  bet.Amount,
+		State: &db.GameState{
+			Amount:       bet.Amount,
+			BetInfo:      string(betInfo),
+			State:        string(pending),
+			UUID:         bet.UUID,
+			GameID:       e.gameId,
+			UserID:       bet.UserID,
+			CoinID:       bet.CoinID,
+			UserSeedID:   seeds.UserSeed.ID,
+			ServerSeedID: seeds.ServerSeed.ID,
+		},
+	})
+	if err != nil {
+		slog.Error("Error taking crash stake", "bet", bet, "err", err)
+		return settlementError(err)
+	}
+
+	seat.seeds = seeds
+	seat.limit = limit
+	return nil
+}
+
+// crashBounds gives the exposure check the most a Crash bet can win.
+type crashBounds struct {
+	params games.CrashParams
+}
+
+// MaxMultiplier is the auto cashout of the bet in bet.Data, or the max multiplier
+// when the bet has none or the round can't reach it.
+func (b crashBounds) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	crashBet := requests.CrashBet{}
+	if err := json.Unmarshal([]byte(bet.Data), &crashBet); err != nil {
+		return decimal.Zero, err
+	}
+	if crashBet.AutoCashout.IsZero() {
+		return b.params.MaxMultiplier, nil
+	}
+	return decimal.Min(crashBet.AutoCashout, b.params.MaxMultiplier), nil
+}
+
+func (e *CrashEngine) betLimit(coinId uint) (db.BetLimit, *BetError) {
+	coin := db.Coin{}
+	if err := e.Db.Where("id = ?", coinId).First(&coin).Error; err != nil {
+		slog.Error("Error getting coin", "coinId", coinId, "err", err)
+		return db.BetLimit{}, errUnknownCoin
+	}
+	limit, err := e.Db.GetBetLimit(e.gameId, coin)
+	if err != nil {
+		slog.Error("Error getting bet limit", "coinId", coinId, "err", err)
+		return db.BetLimit{}, errInternal
+	}
+	return limit, nil
+}
+
+// Cashout pays the bet of the user out at the current multiplier of the running round.
+func (e *CrashEngine) Cashout(userId uint) (responses.CrashCashout, error) {
+	e.mu.Lock()
+	round := e.round
+	if round == nil || round.phase != responses.CrashRunning {
+		e.mu.Unlock()
+		return responses.CrashCashout{}, errRoundClosed
+	}
+	bet, ok := round.bets[userId]
+	if !ok || bet.settled || bet.settling {
+		e.mu.Unlock()
+		return responses.CrashCashout{}, errNoCrashBet
+	}
+
+	multiplier := games.CrashMultiplier(time.Since(round.startedAt))
+	if multiplier.GreaterThanOrEqual(round.CrashPoint) {
+		// the next tick settles the bet as lost
+		e.mu.Unlock()
+		return responses.CrashCashout{}, errCashoutTooLate
+	}
+	e.startSettling(round, bet)
+	e.mu.Unlock()
+
+	dbBet, err := e.settle(round.CrashRound, bet, multiplier)
+	e.doneSettling(round, bet, multiplier, dbBet, err)
+	if err != nil {
+		return responses.CrashCashout{}, err
+	}
+	return responses.CrashCashout{UserID: userId, Multiplier: multiplier, Payout: dbBet.Profit}, nil
+}
+
+// Resume settles the bets a previous run left in game states. Bets of rounds that didn't crash are refunded,
+// the others are paid at their auto cashout if the round reached it and lost otherwise.
+func (e *CrashEngine) Resume() error {
+	// rounds cut short by a stop never finish otherwise
+	err := e.Db.Model(&db.CrashRound{}).Where("NOT finished").Updates(map[string]interface{}{"finished": true, "cancelled": true}).Error
+	if err != nil {
+		return err
+	}
+
+	var states []db.GameState
+	if err := e.Db.Where("game_id = ?", e.gameId).Find(&states).Error; err != nil {
+		return err
+	}
+	for _, state := range states {
+		if err := e.resumeBet(state); err != nil {
+			slog.Error("Error resuming crash bet", "state", state.ID, "userId", state.UserID, "err", err)
+		}
+	}
+	if len(states) > 0 {
+		slog.Info("Crash bets resumed", "bets", len(states))
+	}
+	return nil
+}
+
+func (e *CrashEngine) resumeBet(state db.GameState) error {
+	pending := crashBetState{}
+	if err := json.Unmarshal([]byte(state.State), &pending); err != nil {
+		return err
+	}
+	request := requests.CrashBet{}
+	if err := json.Unmarshal([]byte(state.BetInfo), &request); err != nil {
+		return err
+	}
+	request.UUID = state.UUID
+	request.UserID = state.UserID
+	request.CoinID = state.CoinID
+
+	round := db.CrashRound{}
+	if err := e.Db.Where("id = ?", pending.RoundID).First(&round).Error; err != nil {
+		return err
+	}
+	if round.Cancelled {
+		return e.Db.Settle(db.Settlement{
+			UserID:      state.UserID,
+			CoinID:      state.CoinID,
+			GameID:      e.gameId,
+			Credit:      state.Amount,
+			RemoveState: true,
+		})
+	}
+
+	limit, betErr := e.betLimit(state.CoinID)
+	if betErr != nil {
+		return betErr
+	}
+	bet := &crashBet{
+		request: request,
+		seeds: stepSeeds{
+			UserSeed:   db.UserSeed{ID: state.UserSeedID},
+			ServerSeed: db.ServerSeed{ID: state.ServerSeedID},
+		},
+		limit: limit,
+	}
+	multiplier := decimal.Zero
+	if auto := request.AutoCashout; !auto.IsZero() && auto.LessThan(round.CrashPoint) {
+		multiplier = auto
+	}
+	_, err := e.settle(round, bet, multiplier)
+	return err
+}
+
+// openRound starts the betting window of the next round and returns how long it's open.
+func (e *CrashEngine) openRound() (time.Duration, error) {
+	game := db.Game{}
+	if err := e.Db.Where("id = ?", e.gameId).First(&game).Error; err != nil {
+		return 0, err
+	}
+	params, err := games.ParseCrashParams(game.Parameters)
+	if err != nil {
+		return 0, err
+	}
+
+	round, err := e.nextRound(params)
+	if err != nil {
+		return 0, err
+	}
+	window := time.Duration(params.BettingWindow) * time.Second
+
+	e.mu.Lock()
+	e.round = &crashRound{
+		CrashRound:  round,
+		params:      params,
+		phase:       responses.CrashBetting,
+		bettingEnds: time.Now().Add(window),
+		bets:        make(map[uint]*crashBet),
+	}
+	update := e.round.update(decimal.NewFromInt(1))
+	e.mu.Unlock()
+
+	e.broadcast(update)
+	return window, nil
+}
+
+// nextRound stores the round played with the next hash of the chain,
+// a new chain is started once the current one is used up.
+func (e *CrashEngine) nextRound(params games.CrashParams) (db.CrashRound, error) {
+	chain := db.CrashChain{}
+	err := e.Db.Where("NOT exhausted").Order("id").First(&chain).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		chain, err = e.newChain()
+	}
+	if err != nil {
+		return db.CrashRound{}, err
+	}
+
+	position := uint64(1)
+	last := db.CrashRound{}
+	err = e.Db.Where("chain_id = ?", chain.ID).Order("position DESC").First(&last).Error
+	if err == nil {
+		position = last.Position + 1
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return db.CrashRound{}, err
+	}
+
+	if position > chain.Length {
+		if err := e.Db.Model(&chain).Update("exhausted", true).Error; err != nil {
+			return db.CrashRound{}, err
+		}
+		if chain, err = e.newChain(); err != nil {
+			return db.CrashRound{}, err
+		}
+		position = 1
+	}
+
+	hash := games.CrashChainHash(chain.Seed, chain.Length, position)
+	point, err := games.CrashPoint(hash, params)
+	if err != nil {
+		return db.CrashRound{}, err
+	}
+
+	round := db.CrashRound{
+		Position:   position,
+		Hash:       hash,
+		CrashPoint: point,
+		ChainID:    chain.ID,
+	}
+	return round, e.Db.Create(&round).Error
+}
+
+func (e *CrashEngine) newChain() (db.CrashChain, error) {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return db.CrashChain{}, err
+	}
+
+	chain := db.CrashChain{
+		Seed:   hex.EncodeToString(seed),
+		Length: CrashChainLength,
+	}
+	chain.Commitment = games.CrashChainHash(chain.Seed, chain.Length, 0)
+	if err := e.Db.Create(&chain).Error; err != nil {
+		return db.CrashChain{}, err
+	}
+	slog.Info("New crash chain", "chain", chain.ID, "commitment", chain.Commitment)
+	return chain, nil
+}
+
+// launch closes the betting window and starts the multiplier once the stakes being taken are settled.
+func (e *CrashEngine) launch() {
+	e.mu.Lock()
+	round := e.round
+	round.closing = true
+	e.mu.Unlock()
+
+	round.placing.Wait()
+
+	e.mu.Lock()
+	round.phase = responses.CrashRunning
+	round.startedAt = time.Now()
+	update := round.update(decimal.NewFromInt(1))
+	e.mu.Unlock()
+
+	e.broadcast(update)
+}
+
+// tick advances the running round and pays the auto cashouts it reached,
+// it returns true once the round crashed and every bet is settled.
+func (e *CrashEngine) tick() bool {
+	e.mu.Lock()
+	round := e.round
+	multiplier := games.CrashMultiplier(time.Since(round.startedAt))
+	crashed := multiplier.GreaterThanOrEqual(round.CrashPoint)
+	if crashed {
+		multiplier = round.CrashPoint
+	}
+
+	due := make(map[*crashBet]decimal.Decimal)
+	for _, bet := range round.bets {
+		if bet.settled || bet.settling {
+			continue
+		}
+		auto := bet.request.AutoCashout
+		switch {
+		case !auto.IsZero() && auto.LessThanOrEqual(multiplier) && auto.LessThan(round.CrashPoint):
+			due[bet] = auto
+		case crashed:
+			due[bet] = decimal.Zero
+		default:
+			continue
+		}
+		e.startSettling(round, bet)
+	}
+	e.mu.Unlock()
+
+	for bet, cashout := range due {
+		dbBet, err := e.settle(round.CrashRound, bet, cashout)
+		e.doneSettling(round, bet, cashout, dbBet, err)
+	}
+
+	if crashed {
+		// cashouts that were taken before the crash are still being written
+		round.settling.Wait()
+		if err := e.Db.Model(&round.CrashRound).Update("finished", true).Error; err != nil {
+			slog.Error("Error finishing crash round", "round", round.ID, "err", err)
+		}
+	}
+
+	e.mu.Lock()
+	if crashed {
+		round.phase = responses.CrashCrashed
+	}
+	update := round.update(multiplier)
+	round.cashouts = nil
+	e.mu.Unlock()
+
+	e.broadcast(update)
+	return crashed
+}
+
+// cancelRound refunds the stakes that weren't settled yet, it's used when the server stops mid round.
+func (e *CrashEngine) cancelRound() {
+	e.mu.Lock()
+	round := e.round
+	if round == nil || round.phase == responses.CrashCrashed {
+		e.mu.Unlock()
+		return
+	}
+	// no bet or cashout is taken from now on
+	round.phase = responses.CrashCancelled
+	e.mu.Unlock()
+
+	round.placing.Wait()
+	round.settling.Wait()
+
+	// nothing else touches the bets once they are all settled
+	for _, bet := range round.bets {
+		if bet.settled {
+			continue
+		}
+		err := e.Db.Settle(db.Settlement{
+			UserID:      bet.request.UserID,
+			CoinID:      bet.request.CoinID,
+			GameID:      e.gameId,
+			Credit:      bet.request.Amount,
+			RemoveState: true,
+		})
+		if err != nil {
+			// Resume refunds it on the next start
+			slog.Error("Error refunding crash bet", "round", round.ID, "bet", bet.request, "err", err)
+			continue
+		}
+		bet.settled = true
+	}
+
+	err := e.Db.Model(&round.CrashRound).Updates(map[string]interface{}{"finished": true, "cancelled": true}).Error
+	if err != nil {
+		slog.Error("Error cancelling crash round", "round", round.ID, "err", err)
+	}
+	slog.Info("Crash round cancelled", "round", round.ID, "bets", len(round.bets))
+
+	e.mu.Lock()
+	update := round.update(decimal.NewFromInt(1))
+	e.mu.Unlock()
+
+	e.broadcast(update)
+}
+
+// startSettling keeps other cashouts off the bet while it's written, it's called with the lock held.
+func (e *CrashEngine) startSettling(round *crashRound, bet *crashBet) {
+	bet.settling = true
+	round.settling.Add(1)
+}
+
+// doneSettling records the outcome of settle. A failed cashout can be tried again, a lost bet isn't
+// retried, its stake is already taken and Resume writes it on the next start.
+func (e *CrashEngine) doneSettling(round *crashRound, bet *crashBet, multiplier decimal.Decimal, dbBet db.Bet, err error) {
+	e.mu.Lock()
+	bet.settling = false
+	bet.settled = err == nil || multiplier.IsZero()
+	if err == nil && !multiplier.IsZero() {
+		round.cashouts = append(round.cashouts, responses.CrashCashout{
+			UserID:     bet.request.UserID,
+			Multiplier: multiplier,
+			Payout:     dbBet.Profit,
+		})
+	}
+	e.mu.Unlock()
+	round.settling.Done()
+}
+
+// settle pays the bet out at multiplier, writes it and removes its game state,
+// a zero multiplier settles a lost bet. It's called without the lock.
+func (e *CrashEngine) settle(round db.CrashRound, bet *crashBet, multiplier decimal.Decimal) (db.Bet, error) {
+	payout := bet.request.Amount.Mul(multiplier)
+	result := db.GameResult{
+		TotalProfit: payout,
+		Outcomes:    []uint64{round.CrashPoint.Mul(decimal.NewFromInt(100)).BigInt().Uint64()},
+		Profits:     []decimal.Decimal{payout},
+		NumGames:    1,
+		Finished:    true,
+	}
+	capPayouts(&result, bet.limit)
+
+	outcomes, err := json.Marshal(result.Outcomes)
+	if err != nil {
+		slog.Error("Error marshaling outcomes", "gameResult", result, "err", err)
+		return db.Bet{}, errInternal
+	}
+	profits, err := json.Marshal(result.Profits)
+	if err != nil {
+		slog.Error("Error marshaling profits", "gameResult", result, "err", err)
+		return db.Bet{}, errInternal
+	}
+	betInfo, err := json.Marshal(bet.request)
+	if err != nil {
+		slog.Error("Error marshaling bet", "bet", bet.request, "err", err)
+		return db.Bet{}, errInternal
+	}
+	state, err := json.Marshal(crashBetState{RoundID: round.ID, Cashout: multiplier})
+	if err != nil {
+		slog.Error("Error marshaling crash state", "bet", bet.request, "err", err)
+		return db.Bet{}, errInternal
+	}
+	result.Data = string(state)
+
+	dbBet := db.Bet{
+		Timestamp:    time.Now(),
+		Amount:       bet.request.Amount,
+		Profit:       result.TotalProfit,
+		NumGames:     1,
+		Outcomes:     string(outcomes),
+		Profits:      string(profits),
+		BetInfo:      string(betInfo),
+		State:        result.Data,
+		UUID:         bet.request.UUID,
+		GameID:       e.gameId,
+		UserID:       bet.request.UserID,
+		CoinID:       bet.request.CoinID,
+		UserSeedID:   bet.seeds.UserSeed.ID,
+		ServerSeedID: bet.seeds.ServerSeed.ID,
+	}
+	err = e.Db.Settle(db.Settlement{
+		UserID:      bet.request.UserID,
+		CoinID:      bet.request.CoinID,
+		GameID:      e.gameId,
+		Credit:      result.TotalProfit,
+		Bet:         &dbBet,
+		RemoveState: true,
+	})
+	if err != nil {
+		slog.Error("Error settling crash bet", "round", round.ID, "dbbet", dbBet, "err", err)
+		return db.Bet{}, settlementError(err)
+	}
+
+	propagateBet(e.Db, e.Manager, dbBet)
+	e.Hooks.afterSettle(Bet{Bet: bet.request}, dbBet, result)
+	return dbBet, nil
+}
+
+func (r *crashRound) update(multiplier decimal.Decimal) responses.CrashUpdate {
+	update := responses.CrashUpdate{
+		RoundID:    r.ID,
+		Phase:      r.phase,
+		Multiplier: multiplier,
+		Cashouts:   r.cashouts,
+	}
+	switch r.phase {
+	case responses.CrashBetting:
+		bettingEnds := r.bettingEnds
+		update.BettingEndsAt = &bettingEnds
+	case responses.CrashCrashed, responses.CrashCancelled:
+		crashPoint := r.CrashPoint
+		update.CrashPoint = &crashPoint
+		update.Hash = r.Hash
+	}
+	return update
+}
+
+func (e *CrashEngine) broadcast(update responses.CrashUpdate) {
+	e.Manager.Post(communications.ManagerEvent{
+		Type: communications.PropagateCrash,
+		Body: communications.ManagerEventCrash{
+			GameID: e.gameId,
+			Update: update,
+		},
+	})
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/games"
+	"greekkeepers.io/backend/requests"
+)
+
+func TestCrashBounds(t *testing.T) {
+	bounds := crashBounds{games.CrashParams{MaxMultiplier: decimal.NewFromInt(1000)}}
+	tests := []struct {
+		name        string
+		autoCashout decimal.Decimal
+		multiplier  decimal.Decimal
+	}{
+		{name: "no auto cashout", autoCashout: decimal.Zero, multiplier: decimal.NewFromInt(1000)},
+		{name: "auto cashout", autoCashout: decimal.RequireFromString("2.5"), multiplier: decimal.RequireFromString("2.5")},
+		{name: "auto cashout past the max", autoCashout: decimal.NewFromInt(5000), multiplier: decimal.NewFromInt(1000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(requests.CrashBet{Amount: decimal.NewFromInt(10), AutoCashout: tt.autoCashout})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := bounds.MaxMultiplier(requests.Bet{Data: string(data)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.multiplier) {
+				t.Errorf("max multiplier = %s, want %s", got, tt.multiplier)
+			}
+		})
+	}
+}
+
+func TestReplayCrashBet(t *testing.T) {
+	_, err := ReplaySteps(games.CrashName, nil, nil, nil)
+	if !errors.Is(err, ErrCrashBet) {
+		t.Errorf("err = %v, want %v", err, ErrCrashBet)
+	}
+	_, err = ReplaySteps(games.CrashName, nil, []db.BetStep{{}}, nil)
+	if !errors.Is(err, ErrCrashBet) {
+		t.Errorf("err with steps = %v, want %v", err, ErrCrashBet)
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/shopspring/decimal"
 	"golang.org/x/crypto/blake2b"
+	"gorm.io/gorm"
 	"greekkeepers.io/backend/communications"
 	"greekkeepers.io/backend/db"
 	"greekkeepers.io/backend/games"
@@ -16,55 +18,60 @@ import (
 	"greekkeepers.io/backend/responses"
 )
 
+// ParseStatelessGame builds the stateless engine registered for gameName.
+// It returns nil without an error when gameName is not a stateless game.
 func ParseStatelessGame(
 	gameName string,
 	params string,
 ) (games.StatelessGameEngine, error) {
-	switch gameName {
-	case "CoinFlip":
-		var game games.CoinFlip
-		err := json.Unmarshal([]byte(params), &game)
-		if err != nil {
-			slog.Error("Error parsing CoinFlip", "err", err)
-			return nil, errors.New("Error parsing CoinFlip")
-		}
-		return &game, nil
-	case "Plinko":
-		var game games.Plinko
-		err := json.Unmarshal([]byte(params), &game)
-		if err != nil {
-			slog.Error("Error parsing Plinko", "err", err)
-			return nil, errors.New("Error parsing Plinko")
-		}
-		return &game, nil
+	game, ok, err := games.NewStateless(gameName, params)
+	if err != nil {
+		slog.Error("Error parsing game", "game", gameName, "err", err)
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
 	}
-	return nil, nil
+	return game, nil
 }
 
+// ParseStatefulGame builds the stateful engine registered for gameName.
+// It returns nil without an error when gameName is not a stateful game.
 func ParseStatefulGame(
 	gameName string,
 	params string,
 ) (games.StatefulGameEngine, error) {
-	switch gameName {
-	case "Poker":
-		var game games.Poker
-		err := json.Unmarshal([]byte(params), &game)
-		if err != nil {
-			slog.Error("Error parsing Poker", "err", err)
-			return nil, errors.New("Error parsing Poker")
+	game, ok, err := games.NewStateful(gameName, params)
+	if err != nil {
+		slog.Error("Error parsing game", "game", gameName, "err", err)
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return game, nil
+}
+
+// UnknownGames returns the names of the games that have no registered engine.
+func UnknownGames(gamesRaw []db.Game) []string {
+	unknown := []string{}
+	for _, game := range gamesRaw {
+		if !games.IsRegistered(game.Name) && game.Name != games.CrashName {
+			unknown = append(unknown, game.Name)
 		}
-		return &game, nil
 	}
-	return nil, nil
+	return unknown
 }
 
+// GenerateRandomNumbers derives the random numbers of a bet from its seeds and nonce.
+// Bets placed before nonces were introduced used the unix timestamp of the bet instead.
 func GenerateRandomNumbers(
 	clientSeed string,
 	serverSeed string,
-	timestamp uint64,
+	nonce uint64,
 	amount uint64,
 ) []uint64 {
-	postfix := fmt.Sprintf("%d%s%s", timestamp, clientSeed, serverSeed)
+	postfix := fmt.Sprintf("%d%s%s", nonce, clientSeed, serverSeed)
 
 	result := make([]uint64, amount)
 	for i := range amount {
@@ -77,585 +84,743 @@ func GenerateRandomNumbers(
 	return result
 }
 
+// stepSeeds are what a single step of a bet is played with.
+type stepSeeds struct {
+	UserSeed   db.UserSeed
+	ServerSeed db.ServerSeed
+	Nonce      uint64
+}
+
+// currentSeeds loads the current seeds of the user without reserving a nonce.
+func currentSeeds(Db *db.DB, userId uint) (stepSeeds, *BetError) {
+	s := stepSeeds{}
+	err := Db.Where("user_id = ?", userId).Order("created_at DESC").First(&s.UserSeed).Error
+	if err != nil {
+		slog.Error("Error getting user seed", "userId", userId, "err", err)
+		return s, errNoSeed
+	}
+
+	if err := Db.Where("user_id=? AND revealed=FALSE", userId).First(&s.ServerSeed).Error; err != nil {
+		slog.Error("Error getting server seed", "userId", userId, "err", err)
+		return s, errNoSeed
+	}
+	return s, nil
+}
+
+// nextSeeds loads the current seeds of the user and reserves the next nonce for them.
+func nextSeeds(Db *db.DB, userId uint) (stepSeeds, *BetError) {
+	s, betErr := currentSeeds(Db, userId)
+	if betErr != nil {
+		return s, betErr
+	}
+
+	var err error
+	s.Nonce, err = Db.NextNonce(s.UserSeed.ID, s.ServerSeed.ID)
+	if err != nil {
+		slog.Error("Error getting nonce", "userId", userId, "err", err)
+		return s, errInternal
+	}
+	return s, nil
+}
+
+func (s stepSeeds) randomNumbers(amount uint64) []uint64 {
+	return GenerateRandomNumbers(s.UserSeed.UserSeed, s.ServerSeed.ServerSeed, s.Nonce, amount)
+}
+
+// appendStep adds the step played with the seeds to the JSON list of steps.
+// version and limit are what the result of the step is played and capped with.
+func (s stepSeeds) appendStep(steps string, isContinue bool, request interface{}, version uint, limit db.BetLimit) (string, error) {
+	rawRequest, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	return appendBetStep(steps, db.BetStep{
+		Nonce:         s.Nonce,
+		UserSeedID:    s.UserSeed.ID,
+		ServerSeedID:  s.ServerSeed.ID,
+		Continue:      isContinue,
+		Request:       rawRequest,
+		GameVersionID: version,
+		MaxPayout:     stepMaxPayout(limit),
+	})
+}
+
+func appendBetStep(steps string, step db.BetStep) (string, error) {
+	list := []db.BetStep{}
+	if steps != "" {
+		if err := json.Unmarshal([]byte(steps), &list); err != nil {
+			return "", err
+		}
+	}
+	list = append(list, step)
+
+	result, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
 type Bet struct {
 	IsContinue bool
 	Bet        interface{}
 }
 
+// UserID returns the id of the user that placed the bet.
+func (b Bet) UserID() uint {
+	switch bet := b.Bet.(type) {
+	case requests.Bet:
+		return bet.UserID
+	case requests.ContinueGame:
+		return bet.UserID
+	case ExpireGame:
+		return bet.UserID
+	case AutoBetRound:
+		return bet.UserID
+	}
+	return 0
+}
+
+// ConnectionID returns the UUID of the websocket the bet came from.
+func (b Bet) ConnectionID() string {
+	switch bet := b.Bet.(type) {
+	case requests.Bet:
+		return bet.UUID
+	case requests.ContinueGame:
+		return bet.UUID
+	}
+	return ""
+}
+
+// RequestID returns the id of the websocket request that carried the bet.
+func (b Bet) RequestID() uint {
+	switch bet := b.Bet.(type) {
+	case requests.Bet:
+		return bet.RequestID
+	case requests.ContinueGame:
+		return bet.RequestID
+	}
+	return 0
+}
+
+// GameID returns the id of the game the bet is placed on.
+func (b Bet) GameID() uint {
+	switch bet := b.Bet.(type) {
+	case requests.Bet:
+		return bet.GameID
+	case requests.ContinueGame:
+		return bet.GameID
+	case ExpireGame:
+		return bet.GameID
+	case AutoBetRound:
+		return bet.GameID
+	}
+	return 0
+}
+
+// IdempotencyKey returns the key the client attached to the bet, if any.
+func (b Bet) IdempotencyKey() string {
+	switch bet := b.Bet.(type) {
+	case requests.Bet:
+		return bet.IdempotencyKey
+	case requests.ContinueGame:
+		return bet.IdempotencyKey
+	}
+	return ""
+}
+
+// idempotencyKey returns the key as stored in the database, bets without a key store NULL.
+func idempotencyKey(key string) *string {
+	if key == "" {
+		return nil
+	}
+	return &key
+}
+
 type StatelessEngine struct {
-	BetReceiver        chan Bet
-	StatefulBetChannel chan Bet
-	Games              map[uint]games.StatelessGameEngine
-	Manager            *communications.Manager
-	Db                 *db.DB
+	games   atomic.Pointer[gameSet[games.StatelessGameEngine]]
+	Manager *communications.Manager
+	Db      *db.DB
+	Hooks   *Hooks
 }
 
 func NewStatelessEngine(
-	BetReceiver chan Bet,
-	StatefulBetChannel chan Bet,
 	Manager *communications.Manager,
 	Db *db.DB,
-) StatelessEngine {
-	var gamesRaw []db.Game
-
-	err := Db.Find(&gamesRaw).Error
+) *StatelessEngine {
+	gamesRaw, err := fetchGames(Db)
 	if err != nil {
-		slog.Error("Error retrieving games", "err", err)
 		panic("Error retrieving games")
 	}
 
-	games := make(map[uint]games.StatelessGameEngine)
-	for _, game := range gamesRaw {
-		gameParsed, err := ParseStatelessGame(game.Name, game.Parameters)
-		if err != nil {
-			panic("Error parsing game")
-		}
-		games[game.ID] = gameParsed
+	for _, name := range UnknownGames(gamesRaw) {
+		slog.Warn("Game has no registered engine", "game", name)
+	}
+
+	parsed, err := parseStatelessGames(gamesRaw)
+	if err != nil {
+		panic("Error parsing game")
+	}
+	versions, err := Db.GameVersions(gamesRaw)
+	if err != nil {
+		panic("Error storing game versions")
 	}
 
-	return StatelessEngine{
-		BetReceiver:        BetReceiver,
-		StatefulBetChannel: StatefulBetChannel,
-		Games:              games,
-		Db:                 Db,
-		Manager:            Manager,
+	engine := &StatelessEngine{
+		Db:      Db,
+		Manager: Manager,
+		Hooks:   &Hooks{},
 	}
+	engine.games.Store(&gameSet[games.StatelessGameEngine]{games: parsed, versions: versions})
+	return engine
 }
 
-func (e *StatelessEngine) Run() {
-	slog.Info("Starting stateless engine")
-	for {
-		origBet, ok := <-e.BetReceiver
-		if !ok {
-			slog.Error("Bet Receiver channel is closed")
-			break
-		}
-		slog.Info("Received bet", "bet", origBet)
+// Games returns the games the engine currently plays.
+// The map is replaced as a whole on reload and must not be modified.
+func (e *StatelessEngine) Games() map[uint]games.StatelessGameEngine {
+	return e.games.Load().games
+}
 
-		if origBet.IsContinue {
-			e.StatefulBetChannel <- origBet
-			continue
-		}
+// game returns the game with the version of the parameters it was parsed from.
+func (e *StatelessEngine) game(gameId uint) (games.StatelessGameEngine, uint, bool) {
+	set := e.games.Load()
+	game, ok := set.games[gameId]
+	return game, set.versions[gameId], ok
+}
 
-		bet := origBet.Bet.(requests.Bet)
-		if bet.NumGames > 100 {
-			continue
-		}
+// Process settles a single stateless bet.
+func (e *StatelessEngine) Process(origBet Bet) error {
+	_, err := e.play(origBet)
+	return err
+}
 
-		engine, ok := e.Games[bet.GameID]
-		if !ok || engine == nil {
-			slog.Warn("GameID wasn't found", "bet", bet)
-			e.StatefulBetChannel <- origBet
-			continue
-		}
+// play settles a single stateless bet and returns the bet it stored.
+func (e *StatelessEngine) play(origBet Bet) (db.Bet, error) {
+	slog.Info("Received bet", "bet", origBet)
 
-		coin := db.Coin{}
-		err := e.Db.Where("id = ?", bet.CoinID).First(&coin).Error
-		if err != nil {
-			slog.Error("Error getting coing", "bet", bet, "err", err)
-			continue
-		}
+	bet := origBet.Bet.(requests.Bet)
 
-		fullBetAmount := bet.Amount.Mul(decimal.NewFromUint64(bet.NumGames))
-		fullBetAmountInUsd := fullBetAmount.Div(coin.Price)
+	engine, version, ok := e.game(bet.GameID)
+	if !ok || engine == nil {
+		slog.Warn("GameID wasn't found", "bet", bet)
+		return db.Bet{}, errUnknownGame
+	}
 
-		if fullBetAmountInUsd.GreaterThan(decimal.New(50, 0)) {
-			continue
-		}
+	coin := db.Coin{}
+	err := e.Db.Where("id = ?", bet.CoinID).First(&coin).Error
+	if err != nil {
+		slog.Error("Error getting coing", "bet", bet, "err", err)
+		return db.Bet{}, errUnknownCoin
+	}
 
-		balance := db.Amount{}
-		err = e.Db.Where("coin_id = ? AND user_id = ?", coin.ID, bet.UserID).First(&balance).Error
-		if err != nil {
-			slog.Error("Error getting user balance", "bet", bet, "err", err)
-			continue
-		}
+	limit, err := e.Db.GetBetLimit(bet.GameID, coin)
+	if err != nil {
+		slog.Error("Error getting bet limit", "bet", bet, "err", err)
+		return db.Bet{}, errInternal
+	}
+	if err := checkLimits(bet, limit); err != nil {
+		return db.Bet{}, err
+	}
 
-		if fullBetAmount.GreaterThan(balance.Amount) {
-			continue
-		}
+	fullBetAmount := bet.Amount.Mul(decimal.NewFromUint64(bet.NumGames))
+	if err := checkExposure(e.Db, engine, bet, fullBetAmount); err != nil {
+		return db.Bet{}, err
+	}
 
-		userSeed := &db.UserSeed{}
-		err = e.Db.Where("user_id = ?", bet.UserID).Order("created_at DESC").First(userSeed).Error
-		if err != nil {
-			slog.Error("Error getting user seed", "bet", bet, "err", err)
-			continue
-		}
+	balance := db.Amount{}
+	err = e.Db.Where("coin_id = ? AND user_id = ?", coin.ID, bet.UserID).First(&balance).Error
+	if err != nil {
+		slog.Error("Error getting user balance", "bet", bet, "err", err)
+		return db.Bet{}, errInsufficientBalance
+	}
 
-		serverSeed := &db.ServerSeed{}
-		if err := e.Db.Where("user_id=? AND revealed=FALSE", bet.UserID).First(&serverSeed).Error; err != nil {
-			slog.Error("Failed adding a seed", "bet", bet, "err", err)
-			continue
-		}
+	if fullBetAmount.GreaterThan(balance.Amount) {
+		return db.Bet{}, errInsufficientBalance
+	}
 
-		timeNow := time.Now()
-		timestamp := uint64(timeNow.Unix())
-		randomNumbers := GenerateRandomNumbers(
-			userSeed.UserSeed,
-			serverSeed.ServerSeed,
-			timestamp,
-			engine.NumbersPerBet()*bet.NumGames,
-		)
+	if err := e.Hooks.beforeAccept(bet); err != nil {
+		return db.Bet{}, err
+	}
 
-		gameResult, err := engine.Play(bet, randomNumbers)
-		if err != nil {
-			slog.Warn("Failed to proccess bet", "bet", bet, "err", err)
-			continue
-		}
+	seeds, betErr := nextSeeds(e.Db, bet.UserID)
+	if betErr != nil {
+		return db.Bet{}, betErr
+	}
+	steps, err := seeds.appendStep("", false, bet, version, limit)
+	if err != nil {
+		slog.Error("Error marshaling steps", "bet", bet, "err", err)
+		return db.Bet{}, errInternal
+	}
 
-		totalSpent := bet.Amount.Mul(decimal.NewFromInt32(int32(gameResult.NumGames)))
+	timeNow := time.Now()
+	randomNumbers := seeds.randomNumbers(engine.NumbersPerBet() * bet.NumGames)
 
-		err = e.Db.SubIncBalance(bet.UserID, bet.CoinID, totalSpent, gameResult.TotalProfit)
-		if err != nil {
-			slog.Error("Error updating balance", "bet", bet, "err", err)
-			continue
-		}
+	gameResult, err := engine.Play(bet, randomNumbers)
+	if err != nil {
+		slog.Warn("Failed to proccess bet", "bet", bet, "err", err)
+		return db.Bet{}, rejectBet(responses.BadBetData, err.Error())
+	}
+	capPayouts(&gameResult, limit)
 
-		outcomes, err := json.Marshal(gameResult.Outcomes)
-		if err != nil {
-			slog.Error("Error marshaling outcomes", "gameResult", gameResult, "err", err)
-			continue
-		}
+	totalSpent := bet.Amount.Mul(decimal.NewFromInt32(int32(gameResult.NumGames)))
 
-		profits, err := json.Marshal(gameResult.Profits)
-		if err != nil {
-			slog.Error("Error marshaling profits", "gameResult", gameResult, "err", err)
-			continue
-		}
+	outcomes, err := json.Marshal(gameResult.Outcomes)
+	if err != nil {
+		slog.Error("Error marshaling outcomes", "gameResult", gameResult, "err", err)
+		return db.Bet{}, errInternal
+	}
 
-		dbBet := db.Bet{
+	profits, err := json.Marshal(gameResult.Profits)
+	if err != nil {
+		slog.Error("Error marshaling profits", "gameResult", gameResult, "err", err)
+		return db.Bet{}, errInternal
+	}
+
+	dbBet := db.Bet{
+		Timestamp:    timeNow,
+		Amount:       fullBetAmount,
+		Profit:       gameResult.TotalProfit,
+		NumGames:     int(gameResult.NumGames),
+		Outcomes:     string(outcomes[:]),
+		Profits:      string(profits[:]),
+		BetInfo:      bet.Data,
+		UUID:         bet.UUID,
+		GameID:       bet.GameID,
+		UserID:       bet.UserID,
+		CoinID:       bet.CoinID,
+		UserSeedID:   seeds.UserSeed.ID,
+		ServerSeedID: seeds.ServerSeed.ID,
+		Nonce:        seeds.Nonce,
+		Steps:        steps,
+
+		IdempotencyKey: idempotencyKey(bet.IdempotencyKey),
+	}
+	err = e.Db.Settle(db.Settlement{
+		UserID: bet.UserID,
+		CoinID: bet.CoinID,
+		GameID: bet.GameID,
+		Debit:  totalSpent,
+		Credit: gameResult.TotalProfit,
+		Bet:    &dbBet,
+	})
+	if err != nil {
+		slog.Error("Error settling bet", "bet", bet, "dbbet", dbBet, "err", err)
+		return db.Bet{}, settlementError(err)
+	}
+
+	propagateBet(e.Db, e.Manager, dbBet)
+	e.Hooks.afterSettle(origBet, dbBet, gameResult)
+
+	return dbBet, nil
+}
+
+type StatefulEngine struct {
+	games   atomic.Pointer[gameSet[games.StatefulGameEngine]]
+	Manager *communications.Manager
+	Db      *db.DB
+	Hooks   *Hooks
+}
+
+func NewStatefulEngine(
+	Manager *communications.Manager,
+	Db *db.DB,
+) *StatefulEngine {
+	gamesRaw, err := fetchGames(Db)
+	if err != nil {
+		panic("Error retrieving games")
+	}
+
+	parsed, err := parseStatefulGames(gamesRaw)
+	if err != nil {
+		panic("Error parsing game")
+	}
+	versions, err := Db.GameVersions(gamesRaw)
+	if err != nil {
+		panic("Error storing game versions")
+	}
+
+	engine := &StatefulEngine{
+		Manager: Manager,
+		Db:      Db,
+		Hooks:   &Hooks{},
+	}
+	engine.games.Store(&gameSet[games.StatefulGameEngine]{games: parsed, versions: versions})
+	return engine
+}
+
+// Games returns the games the engine currently plays.
+// The map is replaced as a whole on reload and must not be modified.
+func (e *StatefulEngine) Games() map[uint]games.StatefulGameEngine {
+	return e.games.Load().games
+}
+
+// game returns the game with the version of the parameters it was parsed from.
+func (e *StatefulEngine) game(gameId uint) (games.StatefulGameEngine, uint, bool) {
+	set := e.games.Load()
+	game, ok := set.games[gameId]
+	return game, set.versions[gameId], ok
+}
+
+// PublicState returns the game state as it can be shown to players,
+// the state is left out when the game fails to hide its secrets.
+func (e *StatefulEngine) PublicState(state db.GameState) db.GameState {
+	masked, ok := e.Games()[state.GameID].(games.MaskedGame)
+	if !ok {
+		return state
+	}
+
+	public, err := masked.PublicState(state.State)
+	if err != nil {
+		slog.Error("Error masking game state", "state", state.ID, "err", err)
+		public = ""
+	}
+	state.State = public
+	return state
+}
+
+// Process settles a single stateful bet or continues a game that is in progress.
+func (e *StatefulEngine) Process(origBet Bet) error {
+	if !origBet.IsContinue {
+		return e.start(origBet.Bet.(requests.Bet))
+	}
+	return e.continueGame(origBet.Bet.(requests.ContinueGame))
+}
+
+func (e *StatefulEngine) start(bet requests.Bet) error {
+	engine, version, ok := e.game(bet.GameID)
+	if !ok || engine == nil {
+		slog.Warn("Stateful GameID wasn't found", "bet", bet)
+		return errUnknownGame
+	}
+	// settling a new game replaces the state, a game in progress would be lost with its stake
+	_, err := e.Db.GetGameState(bet.GameID, bet.UserID, bet.CoinID)
+	if err == nil {
+		return errGameInProgress
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.Error("Error getting game state", "bet", bet, "err", err)
+		return errInternal
+	}
+	coin := db.Coin{}
+	err = e.Db.Where("id = ?", bet.CoinID).First(&coin).Error
+	if err != nil {
+		slog.Error("Error getting coing", "bet", bet, "err", err)
+		return errUnknownCoin
+	}
+	limit, err := e.Db.GetBetLimit(bet.GameID, coin)
+	if err != nil {
+		slog.Error("Error getting bet limit", "bet", bet, "err", err)
+		return errInternal
+	}
+	// a stateful game debits the amount once, like the price of bought BigSlots free spins,
+	// so num_games must not scale the checked stake up or down
+	debited := bet
+	debited.NumGames = 1
+	if err := checkLimits(debited, limit); err != nil {
+		return err
+	}
+	if err := checkExposure(e.Db, engine, bet, bet.Amount); err != nil {
+		return err
+	}
+	fullBetAmount := bet.Amount
+	balance := db.Amount{}
+	err = e.Db.Where("coin_id = ? AND user_id = ?", coin.ID, bet.UserID).First(&balance).Error
+	if err != nil {
+		slog.Error("Error getting user balance", "bet", bet, "err", err)
+		return errInsufficientBalance
+	}
+	if fullBetAmount.GreaterThan(balance.Amount) {
+		return errInsufficientBalance
+	}
+	if err := e.Hooks.beforeAccept(bet); err != nil {
+		return err
+	}
+	seeds, betErr := nextSeeds(e.Db, bet.UserID)
+	if betErr != nil {
+		return betErr
+	}
+	steps, err := seeds.appendStep("", false, bet, version, limit)
+	if err != nil {
+		slog.Error("Error marshaling steps", "bet", bet, "err", err)
+		return errInternal
+	}
+	timeNow := time.Now()
+	randomNumbers := seeds.randomNumbers(engine.NumbersPerBet())
+	gameResult, err := engine.StartPlaying(bet, randomNumbers)
+	if err != nil {
+		slog.Warn("Failed to proccess bet", "bet", bet, "err", err)
+		return rejectBet(responses.BadBetData, err.Error())
+	}
+	capPayouts(&gameResult, limit)
+
+	if !gameResult.Finished {
+		// game state changed
+		state := db.GameState{
 			Timestamp:    timeNow,
-			Amount:       fullBetAmount,
-			Profit:       gameResult.TotalProfit,
-			NumGames:     int(gameResult.NumGames),
-			Outcomes:     string(outcomes[:]),
-			Profits:      string(profits[:]),
+			Amount:       bet.Amount,
 			BetInfo:      bet.Data,
+			State:        gameResult.Data,
 			UUID:         bet.UUID,
 			GameID:       bet.GameID,
 			UserID:       bet.UserID,
 			CoinID:       bet.CoinID,
-			UserSeedID:   userSeed.ID,
-			ServerSeedID: serverSeed.ID,
+			UserSeedID:   seeds.UserSeed.ID,
+			ServerSeedID: seeds.ServerSeed.ID,
+			Nonce:        seeds.Nonce,
+			Steps:        steps,
+
+			IdempotencyKey: idempotencyKey(bet.IdempotencyKey),
 		}
-		err = e.Db.Create(&dbBet).Error
+		err := e.Db.Settle(db.Settlement{
+			UserID:   bet.UserID,
+			CoinID:   bet.CoinID,
+			GameID:   bet.GameID,
+			Debit:    bet.Amount,
+			State:    &state,
+			StateKey: idempotencyKey(bet.IdempotencyKey),
+		})
 		if err != nil {
-			slog.Error("Error placing bet", "bet", bet, "dbbet", dbBet, "err", err)
-			continue
+			slog.Error("Error inserting game state", "bet", bet, "err", err)
+			return settlementError(err)
 		}
 
-		var userFull db.User
-		if err := e.Db.Where("id = ?", bet.UserID).First(&userFull).Error; err != nil {
-			slog.Error("User not found", "userId", bet.UserID)
-			continue
-		}
+		e.Manager.Post(communications.ManagerEvent{
+			Type: communications.PropagateState,
+			Body: e.PublicState(state),
+		})
+		e.Hooks.afterStateChange(Bet{Bet: bet}, state, gameResult)
+		return nil
+	}
 
-		constructedBet := responses.Bet{
-			ID:           0,
-			Timestamp:    timeNow,
-			Amount:       fullBetAmount,
-			Profit:       gameResult.TotalProfit,
-			NumGames:     int(gameResult.NumGames),
-			Outcomes:     string(outcomes[:]),
-			Profits:      string(profits[:]),
-			BetInfo:      bet.Data,
-			UUID:         bet.UUID,
-			GameID:       bet.GameID,
-			UserID:       bet.UserID,
-			Username:     userFull.Username,
-			CoinID:       bet.CoinID,
-			UserSeedID:   userSeed.ID,
-			ServerSeedID: serverSeed.ID,
-		}
+	// Game finished
+	outcomes, err := json.Marshal(gameResult.Outcomes)
+	if err != nil {
+		slog.Error("Error marshaling outcomes", "gameResult", gameResult, "err", err)
+		return errInternal
+	}
 
-		e.Manager.ManagerReceiver <- communications.ManagerEvent{
-			Type: communications.PropagateBet,
-			Body: constructedBet,
-		}
+	profits, err := json.Marshal(gameResult.Profits)
+	if err != nil {
+		slog.Error("Error marshaling profits", "gameResult", gameResult, "err", err)
+		return errInternal
+	}
 
+	dbBet := db.Bet{
+		Timestamp:    timeNow,
+		Amount:       fullBetAmount,
+		Profit:       gameResult.TotalProfit,
+		NumGames:     int(gameResult.NumGames),
+		Outcomes:     string(outcomes[:]),
+		Profits:      string(profits[:]),
+		BetInfo:      bet.Data,
+		UUID:         bet.UUID,
+		GameID:       bet.GameID,
+		UserID:       bet.UserID,
+		CoinID:       bet.CoinID,
+		UserSeedID:   seeds.UserSeed.ID,
+		ServerSeedID: seeds.ServerSeed.ID,
+		Nonce:        seeds.Nonce,
+		Steps:        steps,
+
+		IdempotencyKey: idempotencyKey(bet.IdempotencyKey),
+	}
+	err = e.Db.Settle(db.Settlement{
+		UserID:      bet.UserID,
+		CoinID:      bet.CoinID,
+		GameID:      bet.GameID,
+		Debit:       bet.Amount,
+		Credit:      gameResult.TotalProfit,
+		Bet:         &dbBet,
+		RemoveState: true,
+		StateKey:    idempotencyKey(bet.IdempotencyKey),
+	})
+	if err != nil {
+		slog.Error("Error settling bet", "bet", bet, "dbbet", dbBet, "err", err)
+		return settlementError(err)
 	}
-}
 
-type StatefulEngine struct {
-	StatefulBetChannel chan Bet
-	Games              map[uint]games.StatefulGameEngine
-	Manager            *communications.Manager
-	Db                 *db.DB
+	propagateBet(e.Db, e.Manager, dbBet)
+	e.Hooks.afterSettle(Bet{Bet: bet}, dbBet, gameResult)
+	return nil
 }
 
-func NewStatefulEngine(
-	BetReceiver chan Bet,
-	Manager *communications.Manager,
-	Db *db.DB,
-) StatefulEngine {
-	var gamesRaw []db.Game
+func (e *StatefulEngine) continueGame(continueGame requests.ContinueGame) error {
+	engine, version, ok := e.game(continueGame.GameID)
+	if !ok {
+		slog.Warn("Stateful GameID wasn't found", "bet", continueGame)
+		return errUnknownGame
+	}
 
-	err := Db.Find(&gamesRaw).Error
+	state, err := e.Db.GetGameState(continueGame.GameID, continueGame.UserID, continueGame.CoinID)
 	if err != nil {
-		slog.Error("Error retrieving games", "err", err)
-		panic("Error retrieving games")
+		slog.Warn("Error getting game state", "bet", continueGame, "err", err)
+		return errNoGameState
 	}
 
-	games := make(map[uint]games.StatefulGameEngine)
-	for _, game := range gamesRaw {
-		gameParsed, err := ParseStatefulGame(game.Name, game.Parameters)
+	coin := db.Coin{}
+	err = e.Db.Where("id = ?", continueGame.CoinID).First(&coin).Error
+	if err != nil {
+		slog.Error("Error getting coing", "bet", continueGame, "err", err)
+		return errUnknownCoin
+	}
+	limit, err := e.Db.GetBetLimit(continueGame.GameID, coin)
+	if err != nil {
+		slog.Error("Error getting bet limit", "bet", continueGame, "err", err)
+		return errInternal
+	}
+
+	seeds, betErr := nextSeeds(e.Db, continueGame.UserID)
+	if betErr != nil {
+		return betErr
+	}
+	steps, err := seeds.appendStep(state.Steps, true, continueGame, version, limit)
+	if err != nil {
+		slog.Error("Error marshaling steps", "bet", continueGame, "state", state, "err", err)
+		return errInternal
+	}
+
+	timeNow := time.Now()
+	randomNumbers := seeds.randomNumbers(games.NumbersPerStep(engine))
+	gameResult, err := engine.ContinuePlaying(state, continueGame, randomNumbers)
+	if err != nil {
+		slog.Warn("Failed to proccess bet", "bet", continueGame, "state", state, "err", err)
+		return rejectBet(responses.BadBetData, err.Error())
+	}
+	if betErr := checkAddedStake(e.Db, engine, state, continueGame, gameResult.Debit, limit); betErr != nil {
+		return betErr
+	}
+	capPayouts(&gameResult, limit)
+
+	if !gameResult.Finished {
+		// game state changed
+		newState := db.GameState{
+			Timestamp:    timeNow,
+			Amount:       state.Amount.Add(gameResult.Debit),
+			BetInfo:      state.BetInfo,
+			State:        gameResult.Data,
+			UUID:         continueGame.UUID,
+			GameID:       continueGame.GameID,
+			UserID:       continueGame.UserID,
+			CoinID:       continueGame.CoinID,
+			UserSeedID:   seeds.UserSeed.ID,
+			ServerSeedID: seeds.ServerSeed.ID,
+			Nonce:        seeds.Nonce,
+			Steps:        steps,
+
+			IdempotencyKey: idempotencyKey(continueGame.IdempotencyKey),
+		}
+		err := e.Db.Settle(db.Settlement{
+			UserID:   continueGame.UserID,
+			CoinID:   continueGame.CoinID,
+			GameID:   continueGame.GameID,
+			Debit:    gameResult.Debit,
+			State:    &newState,
+			StateKey: idempotencyKey(continueGame.IdempotencyKey),
+		})
 		if err != nil {
-			panic("Error parsing game")
+			slog.Error("Error inserting game state", "bet", continueGame, "err", err)
+			return settlementError(err)
 		}
-		games[game.ID] = gameParsed
+
+		e.Manager.Post(communications.ManagerEvent{
+			Type: communications.PropagateState,
+			Body: e.PublicState(newState),
+		})
+		e.Hooks.afterStateChange(Bet{IsContinue: true, Bet: continueGame}, newState, gameResult)
+		return nil
+	}
+
+	// Game finished
+	outcomes, err := json.Marshal(gameResult.Outcomes)
+	if err != nil {
+		slog.Error("Error marshaling outcomes", "gameResult", gameResult, "err", err)
+		return errInternal
 	}
 
-	return StatefulEngine{
-		StatefulBetChannel: BetReceiver,
-		Games:              games,
-		Manager:            Manager,
-		Db:                 Db,
+	profits, err := json.Marshal(gameResult.Profits)
+	if err != nil {
+		slog.Error("Error marshaling profits", "gameResult", gameResult, "err", err)
+		return errInternal
+	}
+
+	dbBet := db.Bet{
+		Timestamp:    timeNow,
+		Amount:       state.Amount.Add(gameResult.Debit),
+		Profit:       gameResult.TotalProfit,
+		NumGames:     int(gameResult.NumGames),
+		Outcomes:     string(outcomes[:]),
+		Profits:      string(profits[:]),
+		BetInfo:      state.BetInfo,
+		UUID:         continueGame.UUID,
+		GameID:       continueGame.GameID,
+		UserID:       continueGame.UserID,
+		CoinID:       continueGame.CoinID,
+		UserSeedID:   seeds.UserSeed.ID,
+		ServerSeedID: seeds.ServerSeed.ID,
+		Nonce:        seeds.Nonce,
+		Steps:        steps,
+		State:        gameResult.Data,
+
+		IdempotencyKey: idempotencyKey(continueGame.IdempotencyKey),
+	}
+	err = e.Db.Settle(db.Settlement{
+		UserID:      continueGame.UserID,
+		CoinID:      continueGame.CoinID,
+		GameID:      continueGame.GameID,
+		Debit:       gameResult.Debit,
+		Credit:      gameResult.TotalProfit,
+		Bet:         &dbBet,
+		RemoveState: true,
+		StateKey:    idempotencyKey(continueGame.IdempotencyKey),
+	})
+	if err != nil {
+		slog.Error("Error settling bet", "bet", continueGame, "dbbet", dbBet, "err", err)
+		return settlementError(err)
 	}
+
+	propagateBet(e.Db, e.Manager, dbBet)
+	e.Hooks.afterSettle(Bet{IsContinue: true, Bet: continueGame}, dbBet, gameResult)
+	return nil
 }
 
-func (e *StatefulEngine) Run() {
-	slog.Info("Starting stateful engine")
-	for {
-		origBet, ok := <-e.StatefulBetChannel
-		if !ok {
-			slog.Error("Stateful Receiver channel is closed")
-			break
-		}
+// propagateBet broadcasts a settled bet to everyone subscribed to its game.
+func propagateBet(Db *db.DB, Manager *communications.Manager, dbBet db.Bet) {
+	bet, err := betResponse(Db, dbBet)
+	if err != nil {
+		slog.Error("User not found", "userId", dbBet.UserID)
+		return
+	}
 
-		if !origBet.IsContinue {
-			bet := origBet.Bet.(requests.Bet)
-			if bet.NumGames > 100 {
-				continue
-			}
-
-			engine, ok := e.Games[bet.GameID]
-			if !ok || engine == nil {
-				slog.Warn("Stateful GameID wasn't found", "bet", bet)
-				continue
-			}
-			coin := db.Coin{}
-			err := e.Db.Where("id = ?", bet.CoinID).First(&coin).Error
-			if err != nil {
-				slog.Error("Error getting coing", "bet", bet, "err", err)
-				continue
-			}
-			fullBetAmount := bet.Amount.Mul(decimal.NewFromUint64(bet.NumGames))
-			fullBetAmountInUsd := fullBetAmount.Div(coin.Price)
-			if fullBetAmountInUsd.GreaterThan(decimal.New(50, 0)) {
-				continue
-			}
-			balance := db.Amount{}
-			err = e.Db.Where("coin_id = ? AND user_id = ?", coin.ID, bet.UserID).First(&balance).Error
-			if err != nil {
-				slog.Error("Error getting user balance", "bet", bet, "err", err)
-				continue
-			}
-			if fullBetAmount.GreaterThan(balance.Amount) {
-				continue
-			}
-			userSeed := &db.UserSeed{}
-			err = e.Db.Where("user_id = ?", bet.UserID).Order("created_at DESC").First(userSeed).Error
-			if err != nil {
-				slog.Error("Error getting user seed", "bet", bet, "err", err)
-				continue
-			}
-			serverSeed := &db.ServerSeed{}
-			if err := e.Db.Where("user_id=? AND revealed=FALSE", bet.UserID).First(&serverSeed).Error; err != nil {
-				slog.Error("Failed adding a seed", "bet", bet, "err", err)
-				continue
-			}
-			timeNow := time.Now()
-			timestamp := uint64(timeNow.Unix())
-			randomNumbers := GenerateRandomNumbers(
-				userSeed.UserSeed,
-				serverSeed.ServerSeed,
-				timestamp,
-				engine.NumbersPerBet(),
-			)
-			gameResult, err := engine.StartPlaying(bet, randomNumbers)
-			if err != nil {
-				slog.Warn("Failed to proccess bet", "bet", bet, "err", err)
-				continue
-			}
-
-			err = e.Db.DecreaseBalance(bet.UserID, bet.CoinID, bet.Amount)
-			if err != nil {
-				slog.Error("Error updating balance", "bet", bet, "err", err)
-				continue
-			}
-			if gameResult.Finished {
-				// Game finished
-				if !gameResult.TotalProfit.IsZero() {
-					err = e.Db.IncreaseBalance(bet.UserID, bet.CoinID, gameResult.TotalProfit)
-					if err != nil {
-						slog.Error("Error updating balance", "bet", bet, "err", err)
-						continue
-					}
-				}
-
-				outcomes, err := json.Marshal(gameResult.Outcomes)
-				if err != nil {
-					slog.Error("Error marshaling outcomes", "gameResult", gameResult, "err", err)
-					continue
-				}
-
-				profits, err := json.Marshal(gameResult.Profits)
-				if err != nil {
-					slog.Error("Error marshaling profits", "gameResult", gameResult, "err", err)
-					continue
-				}
-
-				dbBet := db.Bet{
-					Timestamp:    timeNow,
-					Amount:       fullBetAmount,
-					Profit:       gameResult.TotalProfit,
-					NumGames:     int(gameResult.NumGames),
-					Outcomes:     string(outcomes[:]),
-					Profits:      string(profits[:]),
-					BetInfo:      bet.Data,
-					UUID:         bet.UUID,
-					GameID:       bet.GameID,
-					UserID:       bet.UserID,
-					CoinID:       bet.CoinID,
-					UserSeedID:   userSeed.ID,
-					ServerSeedID: serverSeed.ID,
-				}
-				err = e.Db.Create(&dbBet).Error
-				if err != nil {
-					slog.Error("Error placing bet", "bet", bet, "dbbet", dbBet, "err", err)
-					continue
-				}
-
-				if gameResult.NumGames > 1 {
-					err := e.Db.RemoveGameState(bet.GameID, bet.UserID, bet.CoinID)
-					if err != nil {
-						slog.Error("Error removing game state", "err", err)
-						continue
-					}
-				}
-
-				var userFull db.User
-				if err := e.Db.Where("id = ?", bet.UserID).First(&userFull).Error; err != nil {
-					slog.Error("User not found", "userId", bet.UserID)
-					continue
-				}
-
-				constructedBet := responses.Bet{
-					ID:           0,
-					Timestamp:    timeNow,
-					Amount:       fullBetAmount,
-					Profit:       gameResult.TotalProfit,
-					NumGames:     int(gameResult.NumGames),
-					Outcomes:     string(outcomes[:]),
-					Profits:      string(profits[:]),
-					BetInfo:      bet.Data,
-					UUID:         bet.UUID,
-					GameID:       bet.GameID,
-					UserID:       bet.UserID,
-					Username:     userFull.Username,
-					CoinID:       bet.CoinID,
-					UserSeedID:   userSeed.ID,
-					ServerSeedID: serverSeed.ID,
-				}
-				e.Manager.ManagerReceiver <- communications.ManagerEvent{
-					Type: communications.PropagateBet,
-					Body: constructedBet,
-				}
-			} else {
-				// game state changed
-				err := e.Db.InsertGameState(
-					bet.GameID,
-					bet.UserID,
-					bet.UUID,
-					bet.CoinID,
-					bet.Data,
-					gameResult.Data,
-					bet.Amount,
-					userSeed.ID,
-					serverSeed.ID,
-					timeNow,
-				)
-				if err != nil {
-					slog.Error("Error inserting game state", "err", err)
-					continue
-				}
-
-				state := db.GameState{
-					ID:           0,
-					Timestamp:    timeNow,
-					Amount:       fullBetAmount,
-					BetInfo:      bet.Data,
-					State:        gameResult.Data,
-					UUID:         bet.UUID,
-					GameID:       bet.GameID,
-					UserID:       bet.UserID,
-					CoinID:       bet.CoinID,
-					UserSeedID:   userSeed.ID,
-					ServerSeedID: serverSeed.ID,
-				}
-
-				e.Manager.ManagerReceiver <- communications.ManagerEvent{
-					Type: communications.PropagateState,
-					Body: state,
-				}
-			}
-		} else {
-			continueGame := origBet.Bet.(requests.ContinueGame)
-
-			engine, ok := e.Games[continueGame.GameID]
-			if !ok {
-				slog.Warn("Stateful GameID wasn't found", "bet", continueGame)
-				continue
-			}
-
-			state, err := e.Db.GetGameState(continueGame.GameID, continueGame.UserID, continueGame.CoinID)
-			if err != nil {
-				slog.Error("Error getting game state")
-				continue
-			}
-
-			userSeed := &db.UserSeed{}
-			err = e.Db.Where("user_id = ?", continueGame.UserID).Order("created_at DESC").First(userSeed).Error
-			if err != nil {
-				slog.Error("Error getting user seed", "bet", continueGame, "err", err)
-				continue
-			}
-			serverSeed := &db.ServerSeed{}
-			if err := e.Db.Where("user_id=? AND revealed=FALSE", continueGame.UserID).First(&serverSeed).Error; err != nil {
-				slog.Error("Failed adding a seed", "bet", continueGame, "err", err)
-				continue
-			}
-
-			timeNow := time.Now()
-			timestamp := uint64(timeNow.Unix())
-			randomNumbers := GenerateRandomNumbers(
-				userSeed.UserSeed,
-				serverSeed.ServerSeed,
-				timestamp,
-				engine.NumbersPerBet(),
-			)
-			gameResult, err := engine.ContinuePlaying(state, continueGame, randomNumbers)
-			if err != nil {
-				slog.Warn("Failed to proccess bet", "bet", continueGame, "state", state, "err", err)
-				continue
-			}
-
-			if gameResult.Finished {
-				// Game finished
-				err := e.Db.RemoveGameState(continueGame.GameID, continueGame.UserID, continueGame.CoinID)
-				if err != nil {
-					slog.Error("Error removing game state", "err", err)
-					continue
-				}
-
-				if !gameResult.TotalProfit.IsZero() {
-					err = e.Db.IncreaseBalance(continueGame.UserID, continueGame.CoinID, gameResult.TotalProfit)
-					if err != nil {
-						slog.Error("Error updating balance", "bet", continueGame, "err", err)
-						continue
-					}
-				}
-
-				outcomes, err := json.Marshal(gameResult.Outcomes)
-				if err != nil {
-					slog.Error("Error marshaling outcomes", "gameResult", gameResult, "err", err)
-					continue
-				}
-
-				profits, err := json.Marshal(gameResult.Profits)
-				if err != nil {
-					slog.Error("Error marshaling profits", "gameResult", gameResult, "err", err)
-					continue
-				}
-
-				dbBet := db.Bet{
-					Timestamp:    timeNow,
-					Amount:       state.Amount,
-					Profit:       gameResult.TotalProfit,
-					NumGames:     int(gameResult.NumGames),
-					Outcomes:     string(outcomes[:]),
-					Profits:      string(profits[:]),
-					BetInfo:      continueGame.Data,
-					UUID:         continueGame.UUID,
-					GameID:       continueGame.GameID,
-					UserID:       continueGame.UserID,
-					CoinID:       continueGame.CoinID,
-					UserSeedID:   userSeed.ID,
-					ServerSeedID: serverSeed.ID,
-					State:        gameResult.Data,
-				}
-				err = e.Db.Create(&dbBet).Error
-				if err != nil {
-					slog.Error("Error placing bet", "bet", continueGame, "dbbet", dbBet, "err", err)
-					continue
-				}
-
-				var userFull db.User
-				if err := e.Db.Where("id = ?", continueGame.UserID).First(&userFull).Error; err != nil {
-					slog.Error("User not found", "userId", continueGame.UserID)
-					continue
-				}
-
-				constructedBet := responses.Bet{
-					ID:           0,
-					Timestamp:    timeNow,
-					Amount:       state.Amount,
-					Profit:       gameResult.TotalProfit,
-					NumGames:     int(gameResult.NumGames),
-					Outcomes:     string(outcomes[:]),
-					Profits:      string(profits[:]),
-					BetInfo:      continueGame.Data,
-					UUID:         continueGame.UUID,
-					GameID:       continueGame.GameID,
-					UserID:       continueGame.UserID,
-					Username:     userFull.Username,
-					CoinID:       continueGame.CoinID,
-					UserSeedID:   userSeed.ID,
-					ServerSeedID: serverSeed.ID,
-					State:        gameResult.Data,
-				}
-				e.Manager.ManagerReceiver <- communications.ManagerEvent{
-					Type: communications.PropagateBet,
-					Body: constructedBet,
-				}
-			} else {
-				// game state changed
-				err := e.Db.InsertGameState(
-					continueGame.GameID,
-					continueGame.UserID,
-					continueGame.UUID,
-					continueGame.CoinID,
-					continueGame.Data,
-					gameResult.Data,
-					state.Amount,
-					userSeed.ID,
-					serverSeed.ID,
-					timeNow,
-				)
-				if err != nil {
-					slog.Error("Error inserting game state", "err", err)
-					continue
-				}
-
-				state := db.GameState{
-					ID:           0,
-					Timestamp:    timeNow,
-					Amount:       state.Amount,
-					BetInfo:      continueGame.Data,
-					State:        gameResult.Data,
-					UUID:         continueGame.UUID,
-					GameID:       continueGame.GameID,
-					UserID:       continueGame.UserID,
-					CoinID:       continueGame.CoinID,
-					UserSeedID:   userSeed.ID,
-					ServerSeedID: serverSeed.ID,
-				}
-
-				e.Manager.ManagerReceiver <- communications.ManagerEvent{
-					Type: communications.PropagateState,
-					Body: state,
-				}
-			}
-		}
+	Manager.Post(communications.ManagerEvent{
+		Type: communications.PropagateBet,
+		Body: bet,
+	})
+}
 
+func betResponse(Db *db.DB, dbBet db.Bet) (responses.Bet, error) {
+	var userFull db.User
+	if err := Db.Where("id = ?", dbBet.UserID).First(&userFull).Error; err != nil {
+		return responses.Bet{}, err
 	}
+
+	return responses.Bet{
+		ID:           dbBet.ID,
+		Timestamp:    dbBet.Timestamp,
+		Amount:       dbBet.Amount,
+		Profit:       dbBet.Profit,
+		NumGames:     dbBet.NumGames,
+		Outcomes:     dbBet.Outcomes,
+		Profits:      dbBet.Profits,
+		BetInfo:      dbBet.BetInfo,
+		State:        dbBet.State,
+		UUID:         dbBet.UUID,
+		Nonce:        dbBet.Nonce,
+		Steps:        dbBet.Steps,
+		GameID:       dbBet.GameID,
+		UserID:       dbBet.UserID,
+		Username:     userFull.Username,
+		CoinID:       dbBet.CoinID,
+		UserSeedID:   dbBet.UserSeedID,
+		ServerSeedID: dbBet.ServerSeedID,
+	}, nil
 }
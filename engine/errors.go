@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"errors"
+
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/responses"
+)
+
+// BetError is returned by the engines when a bet is rejected.
+// Its code and message are sent back to the websocket the bet came from.
+type BetError struct {
+	Code    responses.BetErrorCode
+	Message string
+}
+
+func (e *BetError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+func rejectBet(code responses.BetErrorCode, message string) *BetError {
+	return &BetError{Code: code, Message: message}
+}
+
+var (
+	errTooManyGames        = rejectBet(responses.TooManyGames, "Too many games in a single bet")
+	errStakeTooHigh        = rejectBet(responses.StakeTooHigh, "Bet amount is above the maximum stake")
+	errStakeTooLow         = rejectBet(responses.StakeTooLow, "Bet amount is below the minimum stake")
+	errInsufficientBalance = rejectBet(responses.InsufficientBalance, "Not enough balance")
+	errExposureExceeded    = rejectBet(responses.ExposureExceeded, "Potential win is too high for the bankroll")
+	errUnknownGame         = rejectBet(responses.UnknownGame, "Game not found")
+	errUnknownCoin         = rejectBet(responses.UnknownCoin, "Coin not found")
+	errNoSeed              = rejectBet(responses.NoSeed, "User or server seed is missing")
+	errSeedRevealed        = rejectBet(responses.NoSeed, "Server seed was changed, try again")
+	errNoGameState         = rejectBet(responses.NoGameState, "There is no game in progress")
+	errGameInProgress      = rejectBet(responses.GameInProgress, "Finish the game in progress first")
+	errDuplicateBet        = rejectBet(responses.DuplicateBet, "Idempotency key was already used")
+	errBadIdempotencyKey   = rejectBet(responses.BadBetData, "Idempotency key is too long")
+	errShuttingDown        = rejectBet(responses.ShuttingDown, "Server is shutting down")
+	errInternal            = rejectBet(responses.InternalError, "Internal error")
+)
+
+// settlementError turns an error returned by db.Settle into the rejection sent to the user.
+func settlementError(err error) *BetError {
+	if errors.Is(err, db.ErrInsufficientBalance) {
+		return errInsufficientBalance
+	}
+	if errors.Is(err, db.ErrDuplicateBet) {
+		return errDuplicateBet
+	}
+	if errors.Is(err, db.ErrSeedRevealed) {
+		return errSeedRevealed
+	}
+	return errInternal
+}
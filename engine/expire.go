@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/games"
+)
+
+// SweepInterval is how often the game states are checked for expiry.
+const SweepInterval = time.Minute
+
+// ExpireGame asks the engine to resolve a game state the player abandoned.
+// It goes through the pool like any other bet so it can't race with a continue of the same game.
+type ExpireGame struct {
+	StateID uint
+	GameID  uint
+	UserID  uint
+	CoinID  uint
+}
+
+// stateTTLParams is read from the parameters of every stateful game,
+// a zero state_ttl falls back to the default TTL.
+type stateTTLParams struct {
+	StateTTL uint64 `json:"state_ttl"`
+}
+
+func stateTTL(game db.Game, defaultTTL time.Duration) time.Duration {
+	params := stateTTLParams{}
+	if err := json.Unmarshal([]byte(game.Parameters), &params); err != nil || params.StateTTL == 0 {
+		return defaultTTL
+	}
+	return time.Duration(params.StateTTL) * time.Second
+}
+
+// SweepExpiredGames resolves the game states that weren't touched for longer
+// than the TTL of their game until ctx is done.
+func (p *Pool) SweepExpiredGames(ctx context.Context, defaultTTL time.Duration) {
+	ticker := time.NewTicker(SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.sweepExpiredGames(defaultTTL)
+		}
+	}
+}
+
+func (p *Pool) sweepExpiredGames(defaultTTL time.Duration) {
+	gamesRaw, err := fetchGames(p.Stateful.Db)
+	if err != nil {
+		return
+	}
+
+	statefulGames := p.Stateful.Games()
+	for _, game := range gamesRaw {
+		engine, ok := statefulGames[game.ID]
+		if !ok {
+			continue
+		}
+		if _, ok := engine.(games.ResolvableGame); !ok {
+			continue
+		}
+
+		var expired []db.GameState
+		deadline := time.Now().Add(-stateTTL(game, defaultTTL))
+		err := p.Stateful.Db.Where("game_id = ? AND timestamp < ?", game.ID, deadline).Find(&expired).Error
+		if err != nil {
+			slog.Error("Error getting expired game states", "game", game.Name, "err", err)
+			continue
+		}
+
+		for _, state := range expired {
+			slog.Info("Game state expired", "game", game.Name, "state", state.ID, "userId", state.UserID)
+			p.Submit(Bet{Bet: ExpireGame{
+				StateID: state.ID,
+				GameID:  state.GameID,
+				UserID:  state.UserID,
+				CoinID:  state.CoinID,
+			}})
+		}
+	}
+}
+
+// expire finishes an abandoned game with the rule of the game and settles it like a finished bet.
+func (e *StatefulEngine) expire(expire ExpireGame) error {
+	engine, version, ok := e.game(expire.GameID)
+	if !ok {
+		return errUnknownGame
+	}
+	resolvable, ok := engine.(games.ResolvableGame)
+	if !ok {
+		return errUnknownGame
+	}
+
+	state, err := e.Db.GetGameState(expire.GameID, expire.UserID, expire.CoinID)
+	if err != nil || state.ID != expire.StateID {
+		// the player finished or continued the game after it was picked up by the sweeper
+		return nil
+	}
+
+	coin := db.Coin{}
+	err = e.Db.Where("id = ?", expire.CoinID).First(&coin).Error
+	if err != nil {
+		slog.Error("Error getting coing", "expire", expire, "err", err)
+		return errUnknownCoin
+	}
+	limit, err := e.Db.GetBetLimit(expire.GameID, coin)
+	if err != nil {
+		slog.Error("Error getting bet limit", "expire", expire, "err", err)
+		return errInternal
+	}
+
+	gameResult, err := resolvable.Resolve(state)
+	if err != nil {
+		slog.Error("Error resolving game", "expire", expire, "state", state, "err", err)
+		return errInternal
+	}
+	capPayouts(&gameResult, limit)
+
+	// nothing random is drawn, the step only records that the game was resolved
+	steps, err := appendBetStep(state.Steps, db.BetStep{
+		Nonce:         state.Nonce,
+		UserSeedID:    state.UserSeedID,
+		ServerSeedID:  state.ServerSeedID,
+		Resolve:       true,
+		GameVersionID: version,
+		MaxPayout:     stepMaxPayout(limit),
+	})
+	if err != nil {
+		slog.Error("Error marshaling steps", "expire", expire, "state", state, "err", err)
+		return errInternal
+	}
+
+	outcomes, err := json.Marshal(gameResult.Outcomes)
+	if err != nil {
+		slog.Error("Error marshaling outcomes", "gameResult", gameResult, "err", err)
+		return errInternal
+	}
+
+	profits, err := json.Marshal(gameResult.Profits)
+	if err != nil {
+		slog.Error("Error marshaling profits", "gameResult", gameResult, "err", err)
+		return errInternal
+	}
+
+	dbBet := db.Bet{
+		Timestamp:    time.Now(),
+		Amount:       state.Amount,
+		Profit:       gameResult.TotalProfit,
+		NumGames:     int(gameResult.NumGames),
+		Outcomes:     string(outcomes[:]),
+		Profits:      string(profits[:]),
+		BetInfo:      state.BetInfo,
+		UUID:         state.UUID,
+		GameID:       state.GameID,
+		UserID:       state.UserID,
+		CoinID:       state.CoinID,
+		UserSeedID:   state.UserSeedID,
+		ServerSeedID: state.ServerSeedID,
+		Nonce:        state.Nonce,
+		Steps:        steps,
+		State:        gameResult.Data,
+	}
+	err = e.Db.Settle(db.Settlement{
+		UserID:      state.UserID,
+		CoinID:      state.CoinID,
+		GameID:      state.GameID,
+		Credit:      gameResult.TotalProfit,
+		Bet:         &dbBet,
+		RemoveState: true,
+	})
+	if err != nil {
+		slog.Error("Error settling expired game", "expire", expire, "dbbet", dbBet, "err", err)
+		return settlementError(err)
+	}
+
+	propagateBet(e.Db, e.Manager, dbBet)
+	e.Hooks.afterSettle(Bet{Bet: expire}, dbBet, gameResult)
+	return nil
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"errors"
+	"log/slog"
+
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+	"greekkeepers.io/backend/responses"
+)
+
+// Hook lets other parts of the backend react to bets without touching the engines.
+// Hooks are called from the engine workers, so a slow hook slows down settlement.
+type Hook interface {
+	// BeforeAccept runs before a new bet is played, returning an error rejects the bet.
+	// A *BetError is sent to the user as is, other errors are sent as bet_vetoed.
+	BeforeAccept(bet requests.Bet) error
+	// AfterSettle runs once a finished bet was settled. bet is what finished it:
+	// the requests.Bet, the requests.ContinueGame, the ExpireGame or the requests.CrashBet.
+	AfterSettle(bet Bet, settled db.Bet, result db.GameResult) error
+	// AfterStateChange runs once the state of an unfinished game was saved.
+	AfterStateChange(bet Bet, state db.GameState, result db.GameResult) error
+}
+
+// NopHook can be embedded by hooks that only need some of the calls.
+type NopHook struct{}
+
+func (NopHook) BeforeAccept(requests.Bet) error                         { return nil }
+func (NopHook) AfterSettle(Bet, db.Bet, db.GameResult) error            { return nil }
+func (NopHook) AfterStateChange(Bet, db.GameState, db.GameResult) error { return nil }
+
+// Hooks runs the registered hooks in the order they were registered.
+// The first BeforeAccept error stops the remaining hooks and rejects the bet.
+// The money is already moved when the After calls run, so their errors are only logged
+// and every hook is still called.
+type Hooks struct {
+	hooks []Hook
+}
+
+// Register adds a hook. It must be called at startup, before the pool is running.
+func (h *Hooks) Register(hook Hook) {
+	h.hooks = append(h.hooks, hook)
+}
+
+func (h *Hooks) beforeAccept(bet requests.Bet) *BetError {
+	for _, hook := range h.hooks {
+		err := hook.BeforeAccept(bet)
+		if err == nil {
+			continue
+		}
+
+		var betErr *BetError
+		if errors.As(err, &betErr) {
+			return betErr
+		}
+		return rejectBet(responses.BetVetoed, err.Error())
+	}
+	return nil
+}
+
+func (h *Hooks) afterSettle(bet Bet, settled db.Bet, result db.GameResult) {
+	for _, hook := range h.hooks {
+		if err := hook.AfterSettle(bet, settled, result); err != nil {
+			slog.Error("After settle hook failed", "bet", settled, "err", err)
+		}
+	}
+}
+
+func (h *Hooks) afterStateChange(bet Bet, state db.GameState, result db.GameResult) {
+	for _, hook := range h.hooks {
+		if err := hook.AfterStateChange(bet, state, result); err != nil {
+			slog.Error("After state change hook failed", "state", state, "err", err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/games"
+	"greekkeepers.io/backend/requests"
+	"greekkeepers.io/backend/responses"
+)
+
+// checkLimits rejects bets that fall outside of the limits configured for the game and coin.
+func checkLimits(bet requests.Bet, limit db.BetLimit) *BetError {
+	if !bet.Amount.IsPositive() || bet.Amount.LessThan(limit.MinStake) {
+		return errStakeTooLow
+	}
+	if bet.NumGames > limit.MaxGames {
+		return errTooManyGames
+	}
+
+	fullBetAmount := bet.Amount.Mul(decimal.NewFromUint64(bet.NumGames))
+	if fullBetAmount.GreaterThan(limit.MaxStake) {
+		return errStakeTooHigh
+	}
+
+	return nil
+}
+
+// capPayouts lowers every payout of the result to the max payout of the limit.
+func capPayouts(result *db.GameResult, limit db.BetLimit) {
+	if !limit.MaxPayout.IsPositive() {
+		return
+	}
+
+	for i, profit := range result.Profits {
+		if profit.GreaterThan(limit.MaxPayout) {
+			result.TotalProfit = result.TotalProfit.Sub(profit.Sub(limit.MaxPayout))
+			result.Profits[i] = limit.MaxPayout
+		}
+	}
+	if len(result.Profits) == 0 && result.TotalProfit.GreaterThan(limit.MaxPayout) {
+		result.TotalProfit = limit.MaxPayout
+	}
+}
+
+// stepMaxPayout is the cap recorded on a bet step, nil when the payouts aren't capped.
+func stepMaxPayout(limit db.BetLimit) *decimal.Decimal {
+	if !limit.MaxPayout.IsPositive() {
+		return nil
+	}
+	return &limit.MaxPayout
+}
+
+// checkExposure rejects bets that could win more than the configured fraction of the bankroll.
+// Games that don't know their max multiplier and coins without a bankroll aren't checked.
+func checkExposure(Db *db.DB, game interface{}, bet requests.Bet, stake decimal.Decimal) *BetError {
+	bounded, ok := game.(games.BoundedGame)
+	if !ok {
+		return nil
+	}
+
+	bankroll, err := Db.GetBankroll(bet.CoinID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		slog.Error("Error getting bankroll", "bet", bet, "err", err)
+		return errInternal
+	}
+	return exposureError(bounded, bankroll, bet, stake)
+}
+
+// exposureError compares the potential win of the stake with the part of the bankroll a bet can take.
+func exposureError(bounded games.BoundedGame, bankroll db.Bankroll, bet requests.Bet, stake decimal.Decimal) *BetError {
+	if !bankroll.ExposureFraction.IsPositive() {
+		return nil
+	}
+
+	multiplier, err := bounded.MaxMultiplier(bet)
+	if err != nil {
+		return rejectBet(responses.BadBetData, err.Error())
+	}
+
+	potentialWin := stake.Mul(multiplier).Sub(stake)
+	if potentialWin.GreaterThan(bankroll.Amount.Mul(bankroll.ExposureFraction)) {
+		return errExposureExceeded
+	}
+	return nil
+}
+
+// checkAddedStake rejects a step that takes more stake, like a double or a split. The whole stake
+// of the game counts toward the max stake and the added stake has to fit in the bankroll exposure.
+func checkAddedStake(Db *db.DB, game interface{}, state db.GameState, continueGame requests.ContinueGame, debit decimal.Decimal, limit db.BetLimit) *BetError {
+	if !debit.IsPositive() {
+		return nil
+	}
+	if state.Amount.Add(debit).GreaterThan(limit.MaxStake) {
+		return errStakeTooHigh
+	}
+
+	added := requests.Bet{
+		Amount:   debit,
+		NumGames: 1,
+		Data:     state.BetInfo,
+		GameID:   continueGame.GameID,
+		CoinID:   continueGame.CoinID,
+		UserID:   continueGame.UserID,
+	}
+	return checkExposure(Db, game, added, debit)
+}
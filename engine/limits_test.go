@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/games"
+	"greekkeepers.io/backend/requests"
+)
+
+func TestCheckLimits(t *testing.T) {
+	limit := db.BetLimit{MinStake: decimal.NewFromInt(1), MaxStake: decimal.NewFromInt(100), MaxGames: 10}
+	tests := []struct {
+		name   string
+		amount decimal.Decimal
+		games  uint64
+		err    *BetError
+	}{
+		{name: "within the limits", amount: decimal.NewFromInt(10), games: 10},
+		{name: "below the min stake", amount: decimal.RequireFromString("0.5"), games: 1, err: errStakeTooLow},
+		{name: "no amount", amount: decimal.Zero, games: 1, err: errStakeTooLow},
+		{name: "too many games", amount: decimal.NewFromInt(1), games: 11, err: errTooManyGames},
+		{name: "every game counts toward the max stake", amount: decimal.NewFromInt(11), games: 10, err: errStakeTooHigh},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLimits(requests.Bet{Amount: tt.amount, NumGames: tt.games}, limit)
+			if err != tt.err {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCapPayouts(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxPayout decimal.Decimal
+		result    db.GameResult
+		want      db.GameResult
+	}{
+		{
+			name:      "payouts above the max are lowered",
+			maxPayout: decimal.NewFromInt(50),
+			result:    db.GameResult{TotalProfit: decimal.NewFromInt(130), Profits: []decimal.Decimal{decimal.NewFromInt(100), decimal.NewFromInt(30)}},
+			want:      db.GameResult{TotalProfit: decimal.NewFromInt(80), Profits: []decimal.Decimal{decimal.NewFromInt(50), decimal.NewFromInt(30)}},
+		},
+		{
+			name:      "no max payout",
+			maxPayout: decimal.Zero,
+			result:    db.GameResult{TotalProfit: decimal.NewFromInt(100), Profits: []decimal.Decimal{decimal.NewFromInt(100)}},
+			want:      db.GameResult{TotalProfit: decimal.NewFromInt(100), Profits: []decimal.Decimal{decimal.NewFromInt(100)}},
+		},
+		{
+			name:      "result without profits caps the total",
+			maxPayout: decimal.NewFromInt(50),
+			result:    db.GameResult{TotalProfit: decimal.NewFromInt(100)},
+			want:      db.GameResult{TotalProfit: decimal.NewFromInt(50)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.result
+			capPayouts(&result, db.BetLimit{MaxPayout: tt.maxPayout})
+			if !result.TotalProfit.Equal(tt.want.TotalProfit) {
+				t.Errorf("total profit = %s, want %s", result.TotalProfit, tt.want.TotalProfit)
+			}
+			if len(result.Profits) != len(tt.want.Profits) {
+				t.Fatalf("profits = %v, want %v", result.Profits, tt.want.Profits)
+			}
+			for i := range result.Profits {
+				if !result.Profits[i].Equal(tt.want.Profits[i]) {
+					t.Errorf("profits = %v, want %v", result.Profits, tt.want.Profits)
+				}
+			}
+		})
+	}
+}
+
+// testBigSlotsParams has the seeded free spins prices, the paytable doesn't matter for a buy.
+const testBigSlotsParams = `{
+	"tiles": [{"8": "1"}, {"8": "1"}, {"8": "1"}, {"8": "1"}, {"8": "1"}, {"8": "1"}, {"8": "1"}, {"8": "1"}, {"8": "1"}, {"4": "3"}],
+	"free_spins_prices": {"1": 20000, "2": 200},
+	"free_spins_reward_amount": 15
+}`
+
+// defaultLimit is the limit GetBetLimit returns for a coin without a configured limit.
+func defaultLimit(price decimal.Decimal) db.BetLimit {
+	return db.BetLimit{MaxStake: db.DefaultMaxStakeUsd.Mul(price), MaxGames: db.DefaultMaxGames}
+}
+
+func TestBigSlotsBuyFitsDefaultLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		coinID    uint
+		coinPrice decimal.Decimal
+		price     decimal.Decimal
+	}{
+		{name: "DraxBonus", coinID: 1, coinPrice: decimal.NewFromInt(1000), price: decimal.NewFromInt(20000)},
+		{name: "Drax", coinID: 2, coinPrice: decimal.NewFromInt(10), price: decimal.NewFromInt(200)},
+	}
+
+	game, err := ParseStatefulGame("BigSlots", testBigSlotsParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(games.BigSlotsData{BuyFreeSpins: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the engine checks the price as a single debited game
+			bet := requests.Bet{Amount: tt.price, NumGames: 1, Data: string(data), CoinID: tt.coinID}
+			if _, err := game.StartPlaying(bet, []uint64{0}); err != nil {
+				t.Fatal(err)
+			}
+			if err := checkLimits(bet, defaultLimit(tt.coinPrice)); err != nil {
+				t.Errorf("buy rejected: %s", err.Message)
+			}
+		})
+	}
+}
+
+// testBounded is a game that pays at most multiplier times the stake.
+type testBounded struct {
+	multiplier decimal.Decimal
+}
+
+func (g testBounded) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	return g.multiplier, nil
+}
+
+func TestExposureError(t *testing.T) {
+	bankroll := db.Bankroll{Amount: decimal.NewFromInt(10000), ExposureFraction: decimal.RequireFromString("0.01")}
+	tests := []struct {
+		name       string
+		bankroll   db.Bankroll
+		multiplier decimal.Decimal
+		stake      decimal.Decimal
+		err        *BetError
+	}{
+		{name: "win within the exposure", bankroll: bankroll, multiplier: decimal.NewFromInt(11), stake: decimal.NewFromInt(10)},
+		{name: "win above the exposure", bankroll: bankroll, multiplier: decimal.RequireFromString("11.01"), stake: decimal.NewFromInt(10), err: errExposureExceeded},
+		{name: "the stake isn't part of the win", bankroll: bankroll, multiplier: decimal.NewFromInt(2), stake: decimal.NewFromInt(100)},
+		{name: "no exposure fraction", bankroll: db.Bankroll{Amount: decimal.NewFromInt(10)}, multiplier: decimal.NewFromInt(1000), stake: decimal.NewFromInt(10)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := exposureError(testBounded{tt.multiplier}, tt.bankroll, requests.Bet{}, tt.stake)
+			if err != tt.err {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"greekkeepers.io/backend/communications"
+	"greekkeepers.io/backend/responses"
+)
+
+// WorkerQueueSize is the amount of bets a worker holds before Submit blocks.
+const WorkerQueueSize = 256
+
+// MaxIdempotencyKeyLength is the size of the idempotency_key columns.
+const MaxIdempotencyKeyLength = 64
+
+// Worker settles the bets of the users routed to it one at a time,
+// so bets of a single user are always settled in the order they came in.
+type Worker struct {
+	ID   int
+	Bets chan Bet
+
+	stateless *StatelessEngine
+	stateful  *StatefulEngine
+}
+
+func (w *Worker) Run() {
+	slog.Info("Starting engine worker", "worker", w.ID)
+	for {
+		bet, ok := <-w.Bets
+		if !ok {
+			slog.Info("Engine worker exiting", "worker", w.ID)
+			return
+		}
+
+		if w.replay(bet) {
+			continue
+		}
+		w.reply(bet, w.process(bet))
+	}
+}
+
+// replay answers a bet whose idempotency key was already used with the result
+// of the first bet instead of settling it again. Keys are remembered for as long
+// as the bet or the game state they created exists.
+func (w *Worker) replay(bet Bet) bool {
+	key := bet.IdempotencyKey()
+	if key == "" {
+		return false
+	}
+	if len(key) > MaxIdempotencyKeyLength {
+		w.reply(bet, errBadIdempotencyKey)
+		return true
+	}
+
+	dbBet, state, err := w.stateless.Db.FindByIdempotencyKey(bet.UserID(), key)
+	if err != nil {
+		slog.Error("Error looking up idempotency key", "bet", bet, "err", err)
+		w.reply(bet, errInternal)
+		return true
+	}
+
+	var result interface{}
+	switch {
+	case dbBet != nil:
+		result, err = betResponse(w.stateless.Db, *dbBet)
+		if err != nil {
+			slog.Error("Error building replayed bet", "bet", bet, "err", err)
+			w.reply(bet, errInternal)
+			return true
+		}
+	case state != nil:
+		result = w.stateful.PublicState(*state)
+	default:
+		return false
+	}
+
+	slog.Info("Bet replayed", "bet", bet, "key", key)
+	w.send(bet, responses.BetReply{
+		Accepted: true,
+		Replayed: true,
+		Result:   result,
+	})
+	return true
+}
+
+func (w *Worker) process(bet Bet) error {
+	if round, ok := bet.Bet.(AutoBetRound); ok {
+		// the session gets the outcome of the round, it has no websocket request to reply to
+		round.Done <- w.stateless.autoBetRound(round)
+		return nil
+	}
+	if expire, ok := bet.Bet.(ExpireGame); ok {
+		return w.stateful.expire(expire)
+	}
+	if !bet.IsContinue {
+		if _, ok := w.stateless.Games()[bet.GameID()]; ok {
+			return w.stateless.Process(bet)
+		}
+	}
+	return w.stateful.Process(bet)
+}
+
+// reply tells the websocket that sent the bet whether it was accepted.
+// Bets the server submits itself have no websocket and only get logged.
+func (w *Worker) reply(bet Bet, err error) {
+	reply := responses.BetReply{Accepted: true}
+	if err != nil {
+		var betErr *BetError
+		if !errors.As(err, &betErr) {
+			betErr = errInternal
+		}
+		slog.Info("Bet rejected", "bet", bet, "code", betErr.Code, "message", betErr.Message)
+		reply = responses.BetReply{
+			Accepted: false,
+			Code:     betErr.Code,
+			Message:  betErr.Message,
+		}
+	}
+
+	w.send(bet, reply)
+}
+
+func (w *Worker) send(bet Bet, reply responses.BetReply) {
+	if bet.ConnectionID() == "" {
+		return
+	}
+	w.stateless.Manager.Post(communications.ManagerEvent{
+		Type: communications.ReplyFeed,
+		Body: communications.ManagerEventReply{
+			Id:        bet.ConnectionID(),
+			RequestId: bet.RequestID(),
+			Data:      reply,
+		},
+	})
+}
+
+// Pool shards bets between ENGINES workers by user id.
+type Pool struct {
+	Stateless *StatelessEngine
+	Stateful  *StatefulEngine
+	Workers   []*Worker
+	Hooks     *Hooks
+
+	reloadMu sync.Mutex
+
+	// closing is set by Stop, submitMu keeps Stop from closing a queue Submit is sending to
+	submitMu sync.RWMutex
+	closing  bool
+	running  sync.WaitGroup
+}
+
+func NewPool(workers uint16, stateless *StatelessEngine, stateful *StatefulEngine) *Pool {
+	if workers == 0 {
+		workers = 1
+	}
+
+	// both engines run the same hooks
+	stateful.Hooks = stateless.Hooks
+	pool := &Pool{
+		Stateless: stateless,
+		Stateful:  stateful,
+		Workers:   make([]*Worker, workers),
+		Hooks:     stateless.Hooks,
+	}
+	for i := range pool.Workers {
+		pool.Workers[i] = &Worker{
+			ID:        i,
+			Bets:      make(chan Bet, WorkerQueueSize),
+			stateless: stateless,
+			stateful:  stateful,
+		}
+	}
+
+	return pool
+}
+
+func (p *Pool) Run() {
+	slog.Info("Starting engine pool", "workers", len(p.Workers))
+	for _, worker := range p.Workers {
+		p.running.Add(1)
+		go func() {
+			defer p.running.Done()
+			worker.Run()
+		}()
+	}
+}
+
+// Submit queues the bet on the worker responsible for the user that placed it.
+// Bets submitted after Stop are rejected.
+func (p *Pool) Submit(bet Bet) {
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+
+	worker := p.Workers[bet.UserID()%uint(len(p.Workers))]
+	if p.closing {
+		worker.reply(bet, errShuttingDown)
+		return
+	}
+	worker.Bets <- bet
+}
+
+// Stop stops accepting bets and waits for the workers to settle the queued ones.
+// It returns ctx.Err() if the queues weren't drained before ctx was done.
+func (p *Pool) Stop(ctx context.Context) error {
+	p.submitMu.Lock()
+	if !p.closing {
+		p.closing = true
+		for _, worker := range p.Workers {
+			close(worker.Bets)
+		}
+	}
+	p.submitMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.running.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		slog.Info("Engine pool stopped")
+		return nil
+	case <-ctx.Done():
+		slog.Error("Engine pool didn't drain in time", "queues", p.QueueDepths())
+		return ctx.Err()
+	}
+}
+
+// QueueDepths reports how many bets are waiting on every worker.
+func (p *Pool) QueueDepths() []responses.EngineQueue {
+	depths := make([]responses.EngineQueue, len(p.Workers))
+	for i, worker := range p.Workers {
+		depths[i] = responses.EngineQueue{
+			Worker:   worker.ID,
+			Queued:   len(worker.Bets),
+			Capacity: cap(worker.Bets),
+		}
+	}
+	return depths
+}
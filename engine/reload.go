@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"greekkeepers.io/backend/communications"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/games"
+)
+
+// GamesChannel is notified by the games table trigger whenever a game is changed.
+const GamesChannel = "games_changed"
+
+// gameSet is what an engine plays, it's swapped as a whole on reload.
+// versions has the version of the parameters every game was parsed from.
+type gameSet[T any] struct {
+	games    map[uint]T
+	versions map[uint]uint
+}
+
+func fetchGames(Db *db.DB) ([]db.Game, error) {
+	var gamesRaw []db.Game
+
+	err := Db.Find(&gamesRaw).Error
+	if err != nil {
+		slog.Error("Error retrieving games", "err", err)
+		return nil, err
+	}
+	return gamesRaw, nil
+}
+
+func parseStatelessGames(gamesRaw []db.Game) (map[uint]games.StatelessGameEngine, error) {
+	parsed := make(map[uint]games.StatelessGameEngine)
+	for _, game := range gamesRaw {
+		gameParsed, err := ParseStatelessGame(game.Name, game.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		if gameParsed == nil {
+			continue
+		}
+		parsed[game.ID] = gameParsed
+	}
+	return parsed, nil
+}
+
+func parseStatefulGames(gamesRaw []db.Game) (map[uint]games.StatefulGameEngine, error) {
+	parsed := make(map[uint]games.StatefulGameEngine)
+	for _, game := range gamesRaw {
+		gameParsed, err := ParseStatefulGame(game.Name, game.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		if gameParsed == nil {
+			continue
+		}
+		parsed[game.ID] = gameParsed
+	}
+	return parsed, nil
+}
+
+// Reload re-reads the games table and swaps the games of both engines.
+// Bets that are being settled finish with the games they started with.
+// Nothing is swapped when any of the games fails to parse.
+func (p *Pool) Reload() error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	gamesRaw, err := fetchGames(p.Stateless.Db)
+	if err != nil {
+		return err
+	}
+
+	statelessGames, err := parseStatelessGames(gamesRaw)
+	if err != nil {
+		return err
+	}
+	statefulGames, err := parseStatefulGames(gamesRaw)
+	if err != nil {
+		return err
+	}
+
+	versions, err := p.Stateless.Db.GameVersions(gamesRaw)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range UnknownGames(gamesRaw) {
+		slog.Warn("Game has no registered engine", "game", name)
+	}
+
+	p.Stateless.games.Store(&gameSet[games.StatelessGameEngine]{games: statelessGames, versions: versions})
+	p.Stateful.games.Store(&gameSet[games.StatefulGameEngine]{games: statefulGames, versions: versions})
+
+	gameIds := make([]uint, len(gamesRaw))
+	for i, game := range gamesRaw {
+		gameIds[i] = game.ID
+	}
+	p.Stateless.Manager.Post(communications.ManagerEvent{
+		Type: communications.UpdateGames,
+		Body: communications.ManagerEventUpdateGames{
+			GameIds: gameIds,
+		},
+	})
+
+	slog.Info("Games reloaded", "stateless", len(statelessGames), "stateful", len(statefulGames))
+	return nil
+}
+
+// ListenGameChanges reloads the games every time the games table changes.
+// It keeps reconnecting to the database until ctx is done.
+func (p *Pool) ListenGameChanges(ctx context.Context, dbUrl string) {
+	for ctx.Err() == nil {
+		err := p.listenGameChanges(ctx, dbUrl)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("Listening for game changes failed", "err", err)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (p *Pool) listenGameChanges(ctx context.Context, dbUrl string) error {
+	conn, err := pgx.Connect(ctx, dbUrl)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+GamesChannel); err != nil {
+		return err
+	}
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		if err := p.Reload(); err != nil {
+			slog.Error("Error reloading games", "err", err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/games"
+	"greekkeepers.io/backend/requests"
+)
+
+var ErrNoSteps = errors.New("Bet has no recorded steps")
+
+// ErrCrashBet is returned for Crash bets, their outcome comes from the round hash chain
+// and not from the seeds of the user, the round is checked against the chain commitment instead.
+var ErrCrashBet = errors.New("Crash bets are played from the round hash chain, verify the round against the chain commitment")
+
+// SeedLookup returns the user and server seed a step was played with.
+type SeedLookup func(step db.BetStep) (userSeed string, serverSeed string, err error)
+
+// ParseSteps decodes the steps stored on a bet or a game state.
+func ParseSteps(steps string) ([]db.BetStep, error) {
+	if steps == "" {
+		return nil, ErrNoSteps
+	}
+	parsed := []db.BetStep{}
+	if err := json.Unmarshal([]byte(steps), &parsed); err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return nil, ErrNoSteps
+	}
+	return parsed, nil
+}
+
+// ParamsLookup returns the game parameters a step was played with.
+type ParamsLookup func(step db.BetStep) (string, error)
+
+// VersionParams looks up the parameters of the game versions the steps point to,
+// steps recorded before versions were kept are played with the current parameters.
+func VersionParams(Db *db.DB, game db.Game) ParamsLookup {
+	cache := map[uint]string{}
+	return func(step db.BetStep) (string, error) {
+		if step.GameVersionID == 0 {
+			return game.Parameters, nil
+		}
+		if params, ok := cache[step.GameVersionID]; ok {
+			return params, nil
+		}
+		params, err := Db.GameVersionParameters(game.ID, step.GameVersionID)
+		if err != nil {
+			return "", err
+		}
+		cache[step.GameVersionID] = params
+		return params, nil
+	}
+}
+
+// ReplaySteps plays the steps of a bet again the same way the engines played them,
+// with the parameters and the payout cap of every step, and returns the result of the last step.
+func ReplaySteps(gameName string, params ParamsLookup, steps []db.BetStep, seeds SeedLookup) (db.GameResult, error) {
+	if gameName == games.CrashName {
+		return db.GameResult{}, ErrCrashBet
+	}
+	if len(steps) == 0 {
+		return db.GameResult{}, ErrNoSteps
+	}
+
+	randomNumbers := func(step db.BetStep, amount uint64) ([]uint64, error) {
+		userSeed, serverSeed, err := seeds(step)
+		if err != nil {
+			return nil, err
+		}
+		return GenerateRandomNumbers(userSeed, serverSeed, step.Nonce, amount), nil
+	}
+	capStep := func(result *db.GameResult, step db.BetStep) {
+		if step.MaxPayout != nil {
+			capPayouts(result, db.BetLimit{MaxPayout: *step.MaxPayout})
+		}
+	}
+
+	first := steps[0]
+	if first.Continue {
+		return db.GameResult{}, errors.New("First step of a bet can't be a continue step")
+	}
+	var bet requests.Bet
+	if err := json.Unmarshal(first.Request, &bet); err != nil {
+		return db.GameResult{}, err
+	}
+	firstParams, err := params(first)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+
+	stateless, err := ParseStatelessGame(gameName, firstParams)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	if stateless != nil {
+		if len(steps) != 1 {
+			return db.GameResult{}, fmt.Errorf("Stateless bet has %d steps", len(steps))
+		}
+		numbers, err := randomNumbers(first, stateless.NumbersPerBet()*bet.NumGames)
+		if err != nil {
+			return db.GameResult{}, err
+		}
+		result, err := stateless.Play(bet, numbers)
+		if err != nil {
+			return db.GameResult{}, err
+		}
+		capStep(&result, first)
+		return result, nil
+	}
+
+	// the parameters can change between the steps of a game when the games are reloaded
+	statefulGame := func(step db.BetStep) (games.StatefulGameEngine, error) {
+		stepParams, err := params(step)
+		if err != nil {
+			return nil, err
+		}
+		stateful, err := ParseStatefulGame(gameName, stepParams)
+		if err != nil {
+			return nil, err
+		}
+		if stateful == nil {
+			return nil, fmt.Errorf("Game %s is not registered", gameName)
+		}
+		return stateful, nil
+	}
+
+	stateful, err := statefulGame(first)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	numbers, err := randomNumbers(first, stateful.NumbersPerBet())
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	result, err := stateful.StartPlaying(bet, numbers)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	capStep(&result, first)
+
+	state := db.GameState{
+		Amount:  bet.Amount,
+		BetInfo: bet.Data,
+		State:   result.Data,
+		GameID:  bet.GameID,
+		CoinID:  bet.CoinID,
+	}
+	for i, step := range steps[1:] {
+		if result.Finished {
+			return db.GameResult{}, fmt.Errorf("Game finished before step %d", i+1)
+		}
+		stateful, err := statefulGame(step)
+		if err != nil {
+			return db.GameResult{}, err
+		}
+		if step.Resolve {
+			resolvable, ok := stateful.(games.ResolvableGame)
+			if !ok {
+				return db.GameResult{}, fmt.Errorf("Game %s can't be resolved", gameName)
+			}
+			result, err = resolvable.Resolve(state)
+			if err != nil {
+				return db.GameResult{}, err
+			}
+			capStep(&result, step)
+			continue
+		}
+		if !step.Continue {
+			return db.GameResult{}, fmt.Errorf("Step %d is not a continue step", i+1)
+		}
+
+		var continueGame requests.ContinueGame
+		if err := json.Unmarshal(step.Request, &continueGame); err != nil {
+			return db.GameResult{}, err
+		}
+		numbers, err := randomNumbers(step, games.NumbersPerStep(stateful))
+		if err != nil {
+			return db.GameResult{}, err
+		}
+		result, err = stateful.ContinuePlaying(state, continueGame, numbers)
+		if err != nil {
+			return db.GameResult{}, err
+		}
+		capStep(&result, step)
+
+		state.Amount = state.Amount.Add(result.Debit)
+		state.State = result.Data
+	}
+
+	return result, nil
+}
+
+// ResultMatches reports whether a replayed result has the outcomes and profits stored on the bet.
+func ResultMatches(bet db.Bet, result db.GameResult) (outcomesMatch bool, profitsMatch bool, err error) {
+	var storedOutcomes []uint64
+	if err := json.Unmarshal([]byte(bet.Outcomes), &storedOutcomes); err != nil {
+		return false, false, err
+	}
+	var storedProfits []decimal.Decimal
+	if err := json.Unmarshal([]byte(bet.Profits), &storedProfits); err != nil {
+		return false, false, err
+	}
+
+	outcomesMatch = len(storedOutcomes) == len(result.Outcomes)
+	for i := 0; outcomesMatch && i < len(storedOutcomes); i++ {
+		outcomesMatch = storedOutcomes[i] == result.Outcomes[i]
+	}
+
+	profitsMatch = len(storedProfits) == len(result.Profits) && bet.Profit.Equal(result.TotalProfit)
+	for i := 0; profitsMatch && i < len(storedProfits); i++ {
+		profitsMatch = storedProfits[i].Equal(result.Profits[i])
+	}
+
+	return outcomesMatch, profitsMatch, nil
+}
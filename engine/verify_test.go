@@ -0,0 +1,242 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+)
+
+const (
+	testRaceParams   = `{"profit_coef":"4.9", "cars_amount":5}`
+	testApplesParams = `{"difficulties":[{"mines":1, "total_spaces":3}], "multipliers":[["1.45", "2.1"]]}`
+)
+
+func testSeeds(nonce uint64) stepSeeds {
+	return stepSeeds{
+		UserSeed:   db.UserSeed{ID: 1, UserSeed: "user seed"},
+		ServerSeed: db.ServerSeed{ID: 2, ServerSeed: "server seed"},
+		Nonce:      nonce,
+	}
+}
+
+func testSeedLookup(step db.BetStep) (string, string, error) {
+	if step.UserSeedID != 1 || step.ServerSeedID != 2 {
+		return "", "", fmt.Errorf("unknown seeds %d and %d", step.UserSeedID, step.ServerSeedID)
+	}
+	return "user seed", "server seed", nil
+}
+
+// testVersions looks the parameters of the steps up by their game version.
+func testVersions(current string, versions map[uint]string) ParamsLookup {
+	return func(step db.BetStep) (string, error) {
+		if step.GameVersionID == 0 {
+			return current, nil
+		}
+		params, ok := versions[step.GameVersionID]
+		if !ok {
+			return "", fmt.Errorf("unknown version %d", step.GameVersionID)
+		}
+		return params, nil
+	}
+}
+
+// recordedBet stores the result the way the engines do.
+func recordedBet(t *testing.T, steps string, result db.GameResult) db.Bet {
+	t.Helper()
+	outcomes, err := json.Marshal(result.Outcomes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profits, err := json.Marshal(result.Profits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db.Bet{Profit: result.TotalProfit, Outcomes: string(outcomes), Profits: string(profits), Steps: steps}
+}
+
+// recordStateless plays a bet like Play of the stateless engine.
+func recordStateless(t *testing.T, params string, bet requests.Bet, version uint, limit db.BetLimit) db.Bet {
+	t.Helper()
+	game, err := ParseStatelessGame("Race", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds := testSeeds(7)
+	steps, err := seeds.appendStep("", false, bet, version, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := game.Play(bet, seeds.randomNumbers(game.NumbersPerBet()*bet.NumGames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	capPayouts(&result, limit)
+	return recordedBet(t, steps, result)
+}
+
+func TestReplayStatelessBet(t *testing.T) {
+	bet := requests.Bet{Amount: decimal.NewFromInt(10), NumGames: 20, Data: `{"car":2}`}
+	tests := []struct {
+		name    string
+		bet     func(t *testing.T) db.Bet
+		current string
+		matches bool
+	}{
+		{
+			name:    "recorded bet",
+			bet:     func(t *testing.T) db.Bet { return recordStateless(t, testRaceParams, bet, 0, db.BetLimit{}) },
+			current: testRaceParams,
+			matches: true,
+		},
+		{
+			name: "bet of an older version",
+			bet: func(t *testing.T) db.Bet {
+				return recordStateless(t, testRaceParams, bet, 3, db.BetLimit{})
+			},
+			current: `{"profit_coef":"2", "cars_amount":5}`,
+			matches: true,
+		},
+		{
+			name: "capped bet",
+			bet: func(t *testing.T) db.Bet {
+				return recordStateless(t, testRaceParams, bet, 0, db.BetLimit{MaxPayout: decimal.NewFromInt(20)})
+			},
+			current: testRaceParams,
+			matches: true,
+		},
+		{
+			name: "bet paid more than it won",
+			bet: func(t *testing.T) db.Bet {
+				recorded := recordStateless(t, testRaceParams, bet, 0, db.BetLimit{})
+				recorded.Profit = recorded.Profit.Add(decimal.NewFromInt(1))
+				return recorded
+			},
+			current: testRaceParams,
+			matches: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorded := tt.bet(t)
+			steps, err := ParseSteps(recorded.Steps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params := testVersions(tt.current, map[uint]string{3: testRaceParams})
+			result, err := ReplaySteps("Race", params, steps, testSeedLookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			outcomesMatch, profitsMatch, err := ResultMatches(recorded, result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !outcomesMatch {
+				t.Error("outcomes don't match")
+			}
+			if profitsMatch != tt.matches {
+				t.Errorf("profits match = %v, want %v", profitsMatch, tt.matches)
+			}
+		})
+	}
+}
+
+// An Apples game picks a tile and cashes out, every step with its own nonce and payout cap,
+// the replay has to go through the same steps to reach the stored result.
+func TestReplayStatefulBet(t *testing.T) {
+	game, err := ParseStatefulGame("Apples", testApplesParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := db.BetLimit{MaxPayout: decimal.NewFromInt(12)}
+	bet := requests.Bet{Amount: decimal.NewFromInt(10), NumGames: 1, Data: `{"difficulty":0}`}
+	continues := []requests.ContinueGame{
+		{Data: `{"tile":0}`},
+		{Data: `{"cashout":true}`},
+	}
+
+	// played with the nonce of the seeds as the stateful engine does
+	for nonce := uint64(1); nonce <= 10; nonce++ {
+		t.Run(fmt.Sprintf("nonce %d", nonce), func(t *testing.T) {
+			seeds := testSeeds(nonce)
+			steps, err := seeds.appendStep("", false, bet, 0, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := game.StartPlaying(bet, seeds.randomNumbers(game.NumbersPerBet()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			capPayouts(&result, limit)
+			state := db.GameState{Amount: bet.Amount, BetInfo: bet.Data, State: result.Data}
+			for i, continueGame := range continues {
+				if result.Finished {
+					break
+				}
+				seeds = testSeeds(nonce*100 + uint64(i))
+				if steps, err = seeds.appendStep(steps, true, continueGame, 0, limit); err != nil {
+					t.Fatal(err)
+				}
+				if result, err = game.ContinuePlaying(state, continueGame, seeds.randomNumbers(game.NumbersPerBet())); err != nil {
+					t.Fatal(err)
+				}
+				capPayouts(&result, limit)
+				state.State = result.Data
+			}
+			recorded := recordedBet(t, steps, result)
+
+			parsed, err := ParseSteps(recorded.Steps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replayed, err := ReplaySteps("Apples", testVersions(testApplesParams, nil), parsed, testSeedLookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			outcomesMatch, profitsMatch, err := ResultMatches(recorded, replayed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !outcomesMatch || !profitsMatch {
+				t.Errorf("replayed %v %v, stored %s %s", replayed.Outcomes, replayed.Profits, recorded.Outcomes, recorded.Profits)
+			}
+			if replayed.Data != result.Data {
+				t.Errorf("replayed state %s, want %s", replayed.Data, result.Data)
+			}
+		})
+	}
+}
+
+func TestReplayStepsRejects(t *testing.T) {
+	request, err := json.Marshal(requests.Bet{Amount: decimal.NewFromInt(10), NumGames: 1, Data: `{"car":2}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		game  string
+		steps []db.BetStep
+	}{
+		{name: "no steps", game: "Race"},
+		{name: "continue step first", game: "Apples", steps: []db.BetStep{{UserSeedID: 1, ServerSeedID: 2, Continue: true, Request: request}}},
+		{name: "stateless bet with two steps", game: "Race", steps: []db.BetStep{{UserSeedID: 1, ServerSeedID: 2, Request: request}, {UserSeedID: 1, ServerSeedID: 2, Continue: true, Request: request}}},
+		{name: "unknown seeds", game: "Race", steps: []db.BetStep{{UserSeedID: 5, ServerSeedID: 2, Request: request}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testRaceParams
+			if tt.game == "Apples" {
+				params = testApplesParams
+			}
+			if _, err := ReplaySteps(tt.game, testVersions(params, nil), tt.steps, testSeedLookup); err == nil {
+				t.Error("steps were replayed")
+			}
+		})
+	}
+}
@@ -9,6 +9,10 @@ import (
 	"greekkeepers.io/backend/requests"
 )
 
+func init() {
+	RegisterStateful("Apples", func() StatefulGameEngine { return &Apples{} }, JSONParams)
+}
+
 type ApplesData struct {
 	Difficulty uint8 `json:"difficulty"`
 }
@@ -73,22 +77,13 @@ func (g *Apples) ContinuePlaying(state db.GameState, bet requests.ContinueGame,
 		return db.GameResult{}, err
 	}
 	initialData := ApplesData{}
-	err = json.Unmarshal([]byte(state.BetInfo), &parsedState)
+	err = json.Unmarshal([]byte(state.BetInfo), &initialData)
 	if err != nil {
 		return db.GameResult{}, err
 	}
 
-	if data.Cashout && parsedState.CurrentMultiplier.IsZero() {
-		profit := state.Amount.Mul(parsedState.CurrentMultiplier)
-
-		return db.GameResult{
-			TotalProfit: profit,
-			Outcomes:    make([]uint64, len(parsedState.State)),
-			Profits:     []decimal.Decimal{profit},
-			NumGames:    1,
-			Data:        state.State,
-			Finished:    true,
-		}, nil
+	if data.Cashout && !parsedState.CurrentMultiplier.IsZero() {
+		return applesCashout(state, parsedState), nil
 	}
 
 	difficulty := g.Difficulties[initialData.Difficulty]
@@ -148,6 +143,50 @@ func (g *Apples) ContinuePlaying(state db.GameState, bet requests.ContinueGame,
 	}
 }
 
+// Resolve cashes out an abandoned game at the current multiplier,
+// the stake is returned when no tile was picked yet.
+func (g *Apples) Resolve(state db.GameState) (db.GameResult, error) {
+	parsedState := ApplesState{}
+	err := json.Unmarshal([]byte(state.State), &parsedState)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	if len(parsedState.State) == 0 {
+		return applesResult(state, parsedState, state.Amount), nil
+	}
+	return applesCashout(state, parsedState), nil
+}
+
+func applesCashout(state db.GameState, parsedState ApplesState) db.GameResult {
+	return applesResult(state, parsedState, state.Amount.Mul(parsedState.CurrentMultiplier))
+}
+
+func applesResult(state db.GameState, parsedState ApplesState, profit decimal.Decimal) db.GameResult {
+
+	return db.GameResult{
+		TotalProfit: profit,
+		Outcomes:    make([]uint64, len(parsedState.State)),
+		Profits:     []decimal.Decimal{profit},
+		NumGames:    1,
+		Data:        state.State,
+		Finished:    true,
+	}
+}
+
+func (g *Apples) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	data := ApplesData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if int(data.Difficulty) >= len(g.Multipliers) {
+		return decimal.Zero, errors.New("Bad difficulty")
+	}
+
+	multipliers := g.Multipliers[data.Difficulty]
+	return decimal.Max(multipliers[0], multipliers[1:]...), nil
+}
+
 func (*Apples) NumbersPerBet() uint64 {
 	return 1
 }
@@ -0,0 +1,145 @@
+package games
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+)
+
+func testApples() *Apples {
+	return &Apples{
+		Difficulties: []ApplesDifficulty{{Mines: 1, TotalSpaces: 3}, {Mines: 2, TotalSpaces: 3}},
+		Multipliers: [][]decimal.Decimal{
+			{decimal.RequireFromString("1.45"), decimal.RequireFromString("2.1")},
+			{decimal.RequireFromString("2.91"), decimal.RequireFromString("8.73")},
+		},
+	}
+}
+
+func applesState(t *testing.T, amount decimal.Decimal, difficulty uint8, state ApplesState) db.GameState {
+	t.Helper()
+	raw, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betInfo, err := json.Marshal(ApplesData{Difficulty: difficulty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db.GameState{Amount: amount, BetInfo: string(betInfo), State: string(raw)}
+}
+
+// The cashout used to be taken only when the multiplier was zero, paying nothing for a won row
+// and ending the game before a tile was picked.
+func TestApplesCashout(t *testing.T) {
+	amount := decimal.NewFromInt(10)
+	tests := []struct {
+		name     string
+		state    ApplesState
+		finished bool
+		profit   decimal.Decimal
+	}{
+		{
+			name:     "cashes out at the current multiplier",
+			state:    ApplesState{State: [][]bool{{true, false, false}}, PickedTiles: []uint8{1}, CurrentMultiplier: decimal.RequireFromString("1.45")},
+			finished: true,
+			profit:   decimal.RequireFromString("14.5"),
+		},
+		{
+			name:     "nothing to cash out before a tile is picked",
+			state:    ApplesState{State: [][]bool{}, PickedTiles: []uint8{}, CurrentMultiplier: decimal.Zero},
+			finished: false,
+			profit:   decimal.RequireFromString("14.5"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := json.Marshal(ApplesContinueData{Tile: 1, Cashout: true})
+			// the mine is under tile 0, so a tile picked instead of the cashout wins the first row
+			result, err := testApples().ContinuePlaying(applesState(t, amount, 0, tt.state), requests.ContinueGame{Data: string(data)}, []uint64{0})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Finished != tt.finished {
+				t.Errorf("finished = %v, want %v", result.Finished, tt.finished)
+			}
+			if !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("profit = %s, want %s", result.TotalProfit, tt.profit)
+			}
+		})
+	}
+}
+
+// The difficulty is read from the data of the bet that started the game.
+func TestApplesDifficulty(t *testing.T) {
+	amount := decimal.NewFromInt(10)
+	tests := []struct {
+		name       string
+		difficulty uint8
+		tile       uint8
+		finished   bool
+		profit     decimal.Decimal
+	}{
+		{name: "one mine hides under the first tile", difficulty: 0, tile: 0, finished: true, profit: decimal.Zero},
+		{name: "one mine spares the others", difficulty: 0, tile: 2, profit: decimal.RequireFromString("14.5")},
+		{name: "two mines leave the first tile empty", difficulty: 1, tile: 0, profit: decimal.RequireFromString("29.1")},
+		{name: "two mines cover the others", difficulty: 1, tile: 2, finished: true, profit: decimal.Zero},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := json.Marshal(ApplesContinueData{Tile: tt.tile})
+			state := ApplesState{State: [][]bool{}, PickedTiles: []uint8{}, CurrentMultiplier: decimal.Zero}
+			// the random number 0 puts the single mine, or the single empty tile, on tile 0
+			result, err := testApples().ContinuePlaying(applesState(t, amount, tt.difficulty, state), requests.ContinueGame{Data: string(data)}, []uint64{0})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Finished != tt.finished {
+				t.Errorf("finished = %v, want %v", result.Finished, tt.finished)
+			}
+			if !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("profit = %s, want %s", result.TotalProfit, tt.profit)
+			}
+		})
+	}
+}
+
+func TestApplesResolve(t *testing.T) {
+	amount := decimal.NewFromInt(10)
+	tests := []struct {
+		name   string
+		state  ApplesState
+		profit decimal.Decimal
+	}{
+		{
+			name:   "returns the stake of an untouched game",
+			state:  ApplesState{State: [][]bool{}, PickedTiles: []uint8{}, CurrentMultiplier: decimal.Zero},
+			profit: amount,
+		},
+		{
+			name:   "cashes out a played game",
+			state:  ApplesState{State: [][]bool{{true, false, false}, {false, true, false}}, PickedTiles: []uint8{1, 0}, CurrentMultiplier: decimal.RequireFromString("2.1")},
+			profit: decimal.NewFromInt(21),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := testApples().Resolve(applesState(t, amount, 0, tt.state))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Finished {
+				t.Error("resolved game isn't finished")
+			}
+			if !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("profit = %s, want %s", result.TotalProfit, tt.profit)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package games
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+)
+
+const (
+	BigSlotsColumns = 6
+	BigSlotsRows    = 5
+	BigSlotsTiles   = BigSlotsColumns * BigSlotsRows
+	// BigSlotsBuyCost is how many stakes a free spins buy costs, bought spins are played
+	// with the price divided by it. Free spins pay about 34 stakes with the weights below.
+	BigSlotsBuyCost = 35
+	// bigSlotsTriggerScatters is the amount of scatters that awards free spins
+	bigSlotsTriggerScatters = 4
+)
+
+// bigSlotsWeights are how often every tile lands, the last tile is the scatter.
+// They are tuned with cmd/simulate to an RTP of about 0.97 for the seeded paytable.
+var bigSlotsWeights = []uint64{95, 85, 70, 60, 50, 40, 33, 25, 20, 13}
+
+func init() {
+	RegisterStateful("BigSlots", func() StatefulGameEngine { return &BigSlots{} }, decodeBigSlots)
+}
+
+// BigSlotsData starts a game with a base spin, or with free spins when BuyFreeSpins is set.
+type BigSlotsData struct {
+	BuyFreeSpins bool `json:"buy_free_spins"`
+}
+
+type BigSlotsSpin struct {
+	// Tumbles has every grid of the spin column by column, winning tiles are replaced in the next one
+	Tumbles    [][]uint8       `json:"tumbles"`
+	Scatters   uint64          `json:"scatters"`
+	Multiplier decimal.Decimal `json:"multiplier"`
+	Win        decimal.Decimal `json:"win"`
+}
+
+// BigSlotsState is kept while free spins are left, so they survive reconnects.
+type BigSlotsState struct {
+	Stake     decimal.Decimal `json:"stake"`
+	FreeSpins uint64          `json:"free_spins"`
+	TotalWin  decimal.Decimal `json:"total_win"`
+	LastSpin  *BigSlotsSpin   `json:"last_spin,omitempty"`
+}
+
+// BigSlots is a tumbling slot that pays clusters anywhere on the grid.
+// Tiles[i] maps a cluster size to the multiplier of tile i, the last tile is the scatter.
+// During free spins a random multiplier from Multipliers lands with MultiplierChance percent.
+type BigSlots struct {
+	Tiles                 []map[string]decimal.Decimal `json:"tiles"`
+	Multipliers           []decimal.Decimal            `json:"multipliers"`
+	MultiplierChance      uint64                       `json:"multiplier_chance"`
+	FreeSpinsPrices       map[string]decimal.Decimal   `json:"free_spins_prices"`
+	FreeSpinsRewardAmount uint64                       `json:"free_spins_reward_amount"`
+	// MaxWin caps the whole game at this many stakes
+	MaxWin decimal.Decimal `json:"max_win"`
+
+	paytable []bigSlotsPays
+}
+
+// bigSlotsPays are the cluster sizes of a tile in ascending order with their multipliers.
+type bigSlotsPays struct {
+	sizes       []uint64
+	multipliers []decimal.Decimal
+}
+
+func decodeBigSlots(params string, game interface{}) error {
+	g := game.(*BigSlots)
+	if err := json.Unmarshal([]byte(params), g); err != nil {
+		return err
+	}
+	if len(g.Tiles) != len(bigSlotsWeights) {
+		return fmt.Errorf("BigSlots need %d tiles", len(bigSlotsWeights))
+	}
+	if g.MultiplierChance > 100 || (g.MultiplierChance > 0 && len(g.Multipliers) == 0) {
+		return errors.New("Bad random multipliers")
+	}
+	if g.FreeSpinsRewardAmount == 0 {
+		return errors.New("free_spins_reward_amount must be positive")
+	}
+	if g.MaxWin.IsZero() {
+		g.MaxWin = decimal.NewFromInt(5000)
+	}
+
+	g.paytable = make([]bigSlotsPays, len(g.Tiles))
+	for tile, pays := range g.Tiles {
+		for size := range pays {
+			parsed, err := strconv.ParseUint(size, 10, 64)
+			if err != nil {
+				return fmt.Errorf("Bad cluster size %q of tile %d", size, tile)
+			}
+			g.paytable[tile].sizes = append(g.paytable[tile].sizes, parsed)
+		}
+		sort.Slice(g.paytable[tile].sizes, func(i, j int) bool {
+			return g.paytable[tile].sizes[i] < g.paytable[tile].sizes[j]
+		})
+		for _, size := range g.paytable[tile].sizes {
+			g.paytable[tile].multipliers = append(g.paytable[tile].multipliers, pays[strconv.FormatUint(size, 10)])
+		}
+	}
+	return nil
+}
+
+func (g *BigSlots) StartPlaying(bet requests.Bet, randomNumbers []uint64) (db.GameResult, error) {
+	data := BigSlotsData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+
+	if data.BuyFreeSpins {
+		price, ok := g.FreeSpinsPrices[strconv.FormatUint(uint64(bet.CoinID), 10)]
+		if !ok {
+			return db.GameResult{}, errors.New("Free spins can't be bought with this coin")
+		}
+		if !bet.Amount.Equal(price) {
+			return db.GameResult{}, fmt.Errorf("Free spins cost %s", price)
+		}
+		return bigSlotsResult(BigSlotsState{
+			Stake:     price.Div(decimal.NewFromInt(BigSlotsBuyCost)),
+			FreeSpins: g.FreeSpinsRewardAmount,
+			TotalWin:  decimal.Zero,
+		}, nil)
+	}
+
+	spin := g.spin(bet.Amount, newBigSlotsRng(randomNumbers[0]), false)
+	state := BigSlotsState{
+		Stake:    bet.Amount,
+		TotalWin: spin.Win,
+		LastSpin: &spin,
+	}
+	if spin.Scatters >= bigSlotsTriggerScatters {
+		state.FreeSpins = g.FreeSpinsRewardAmount
+	}
+	return g.capped(state)
+}
+
+// ContinuePlaying plays the next free spin, the data of the request isn't used.
+func (g *BigSlots) ContinuePlaying(state db.GameState, bet requests.ContinueGame, randomNumbers []uint64) (db.GameResult, error) {
+	parsedState := BigSlotsState{}
+	err := json.Unmarshal([]byte(state.State), &parsedState)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	if parsedState.FreeSpins == 0 {
+		return db.GameResult{}, errors.New("No free spins left")
+	}
+
+	spin := g.spin(parsedState.Stake, newBigSlotsRng(randomNumbers[0]), true)
+	parsedState.FreeSpins--
+	if spin.Scatters >= bigSlotsTriggerScatters {
+		parsedState.FreeSpins += g.FreeSpinsRewardAmount
+	}
+	parsedState.TotalWin = parsedState.TotalWin.Add(spin.Win)
+	parsedState.LastSpin = &spin
+	return g.capped(parsedState)
+}
+
+// capped ends the game once it reached the max win.
+func (g *BigSlots) capped(state BigSlotsState) (db.GameResult, error) {
+	maxWin := state.Stake.Mul(g.MaxWin)
+	if state.TotalWin.GreaterThanOrEqual(maxWin) {
+		state.TotalWin = maxWin
+		state.FreeSpins = 0
+	}
+	return bigSlotsResult(state, state.LastSpin)
+}
+
+func bigSlotsResult(state BigSlotsState, spin *BigSlotsSpin) (db.GameResult, error) {
+	stringState, err := json.Marshal(state)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+
+	outcomes := []uint64{}
+	if spin != nil {
+		for _, tile := range spin.Tumbles[0] {
+			outcomes = append(outcomes, uint64(tile))
+		}
+	}
+
+	finished := state.FreeSpins == 0
+	profits := []decimal.Decimal{}
+	totalProfit := decimal.Zero
+	if finished {
+		totalProfit = state.TotalWin
+		profits = append(profits, totalProfit)
+	}
+	return db.GameResult{
+		TotalProfit: totalProfit,
+		Outcomes:    outcomes,
+		Profits:     profits,
+		NumGames:    1,
+		Data:        string(stringState),
+		Finished:    finished,
+	}, nil
+}
+
+// spin drops a full grid and tumbles it until no cluster pays anymore.
+func (g *BigSlots) spin(stake decimal.Decimal, rng *bigSlotsRng, freeSpin bool) BigSlotsSpin {
+	scatter := uint8(len(bigSlotsWeights) - 1)
+	spin := BigSlotsSpin{Win: decimal.Zero, Multiplier: decimal.Zero}
+
+	grid := make([]uint8, BigSlotsTiles)
+	for i := range grid {
+		grid[i] = rng.tile()
+	}
+
+	for {
+		spin.Tumbles = append(spin.Tumbles, grid)
+
+		counts := make([]uint64, len(bigSlotsWeights))
+		for _, tile := range grid {
+			counts[tile]++
+		}
+		winning := make([]bool, len(bigSlotsWeights))
+		won := false
+		for tile, count := range counts {
+			if uint8(tile) == scatter {
+				continue
+			}
+			if multiplier := g.pays(tile, count); multiplier.IsPositive() {
+				spin.Win = spin.Win.Add(stake.Mul(multiplier))
+				winning[tile] = true
+				won = true
+			}
+		}
+		if !won {
+			spin.Scatters = counts[scatter]
+			break
+		}
+
+		// winning tiles disappear, the rest of the column falls down and new tiles fill the top
+		next := make([]uint8, 0, BigSlotsTiles)
+		for column := range BigSlotsColumns {
+			kept := []uint8{}
+			for _, tile := range grid[column*BigSlotsRows : (column+1)*BigSlotsRows] {
+				if !winning[tile] {
+					kept = append(kept, tile)
+				}
+			}
+			for len(kept) < BigSlotsRows {
+				kept = append([]uint8{rng.tile()}, kept...)
+			}
+			next = append(next, kept...)
+		}
+		grid = next
+	}
+
+	spin.Win = spin.Win.Add(stake.Mul(g.pays(int(scatter), spin.Scatters)))
+
+	if freeSpin && g.MultiplierChance > 0 && rng.next()%100 < g.MultiplierChance {
+		spin.Multiplier = g.Multipliers[rng.next()%uint64(len(g.Multipliers))]
+		spin.Win = spin.Win.Mul(spin.Multiplier)
+	}
+	return spin
+}
+
+// pays returns the multiplier of the biggest configured cluster size that count reaches.
+func (g *BigSlots) pays(tile int, count uint64) decimal.Decimal {
+	pays := g.paytable[tile]
+	multiplier := decimal.Zero
+	for i, size := range pays.sizes {
+		if count < size {
+			break
+		}
+		multiplier = pays.multipliers[i]
+	}
+	return multiplier
+}
+
+// MaxMultiplier is MaxWin of the stake, bought free spins are played at the price divided by BigSlotsBuyCost.
+func (g *BigSlots) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	data := BigSlotsData{}
+	if err := json.Unmarshal([]byte(bet.Data), &data); err != nil {
+		return decimal.Zero, err
+	}
+	if data.BuyFreeSpins {
+		return g.MaxWin.Div(decimal.NewFromInt(BigSlotsBuyCost)), nil
+	}
+	return g.MaxWin, nil
+}
+
+// NumbersPerBet is a single number that seeds every tile of the spin.
+func (*BigSlots) NumbersPerBet() uint64 {
+	return 1
+}
+
+// bigSlotsRng expands the random number of a step with splitmix64,
+// a spin needs an unknown amount of tiles because of the tumbles.
+type bigSlotsRng struct {
+	state uint64
+}
+
+func newBigSlotsRng(seed uint64) *bigSlotsRng {
+	return &bigSlotsRng{state: seed}
+}
+
+func (r *bigSlotsRng) next() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (r *bigSlotsRng) tile() uint8 {
+	total := uint64(0)
+	for _, weight := range bigSlotsWeights {
+		total += weight
+	}
+
+	number := r.next() % total
+	for tile, weight := range bigSlotsWeights {
+		if number < weight {
+			return uint8(tile)
+		}
+		number -= weight
+	}
+	return uint8(len(bigSlotsWeights) - 1)
+}
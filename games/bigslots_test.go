@@ -0,0 +1,262 @@
+package games
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/requests"
+)
+
+// testBigSlotsParams is the seeded paytable, the sizes that pay the same as the size below are left out.
+const testBigSlotsParams = `{
+	"tiles": [
+		{"8": "0.25", "10": "0.75", "12": "2"},
+		{"8": "0.40", "10": "0.90", "12": "4"},
+		{"8": "0.50", "10": "1", "12": "5"},
+		{"8": "0.80", "10": "1.20", "12": "8"},
+		{"8": "1", "10": "1.50", "12": "10"},
+		{"8": "1.5", "10": "2", "12": "12"},
+		{"8": "2", "10": "5", "12": "15"},
+		{"8": "2.5", "10": "10", "12": "25"},
+		{"8": "10", "10": "25", "12": "50"},
+		{"4": "3", "5": "5", "6": "100"}
+	],
+	"multipliers": ["2", "8", "15", "25", "100"],
+	"multiplier_chance": 10,
+	"free_spins_prices": {"2": "350"},
+	"free_spins_reward_amount": 15
+}`
+
+func testBigSlots(t *testing.T) *BigSlots {
+	t.Helper()
+	g := &BigSlots{}
+	if err := decodeBigSlots(testBigSlotsParams, g); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestBigSlotsPays(t *testing.T) {
+	tests := []struct {
+		name  string
+		tile  int
+		count uint64
+		want  decimal.Decimal
+	}{
+		{name: "cluster too small", tile: 0, count: 7, want: decimal.Zero},
+		{name: "smallest cluster", tile: 0, count: 8, want: decimal.RequireFromString("0.25")},
+		{name: "between sizes", tile: 0, count: 11, want: decimal.RequireFromString("0.75")},
+		{name: "past the biggest size", tile: 8, count: 30, want: decimal.NewFromInt(50)},
+		{name: "no scatters", tile: 9, count: 3, want: decimal.Zero},
+		{name: "scatters", tile: 9, count: 5, want: decimal.NewFromInt(5)},
+	}
+
+	g := testBigSlots(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.pays(tt.tile, tt.count); !got.Equal(tt.want) {
+				t.Errorf("pays = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBigSlotsBuyFreeSpins(t *testing.T) {
+	tests := []struct {
+		name   string
+		coinID uint
+		amount decimal.Decimal
+		fails  bool
+	}{
+		{name: "bought at the price", coinID: 2, amount: decimal.NewFromInt(350)},
+		{name: "amount isn't the price", coinID: 2, amount: decimal.NewFromInt(35), fails: true},
+		{name: "coin without a price", coinID: 1, amount: decimal.NewFromInt(350), fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bet := requests.Bet{Amount: tt.amount, CoinID: tt.coinID, Data: testData(t, BigSlotsData{BuyFreeSpins: true})}
+			result, err := testBigSlots(t).StartPlaying(bet, []uint64{1})
+			if tt.fails {
+				if err == nil {
+					t.Fatal("bet was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state := BigSlotsState{}
+			if err := json.Unmarshal([]byte(result.Data), &state); err != nil {
+				t.Fatal(err)
+			}
+			if result.Finished || state.FreeSpins != 15 {
+				t.Errorf("finished = %v with %d free spins", result.Finished, state.FreeSpins)
+			}
+			// the spins are played with the price divided by the buy cost
+			if !state.Stake.Equal(decimal.NewFromInt(10)) {
+				t.Errorf("stake = %s, want 10", state.Stake)
+			}
+		})
+	}
+}
+
+func TestBigSlotsContinuePlaying(t *testing.T) {
+	stake := decimal.NewFromInt(10)
+	tests := []struct {
+		name     string
+		state    BigSlotsState
+		fails    bool
+		finished bool
+		// capped is set when the max win ends the game
+		capped bool
+	}{
+		{
+			name:  "free spin left",
+			state: BigSlotsState{Stake: stake, FreeSpins: 2, TotalWin: decimal.Zero},
+		},
+		{
+			name:     "last free spin pays the game",
+			state:    BigSlotsState{Stake: stake, FreeSpins: 1, TotalWin: decimal.NewFromInt(120)},
+			finished: true,
+		},
+		{
+			name:     "max win ends the free spins",
+			state:    BigSlotsState{Stake: stake, FreeSpins: 10, TotalWin: decimal.NewFromInt(50_000)},
+			finished: true,
+			capped:   true,
+		},
+		{
+			name:  "no free spins left",
+			state: BigSlotsState{Stake: stake, FreeSpins: 0, TotalWin: decimal.Zero},
+			fails: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a spin without scatters, retriggered free spins would keep the game going
+			var numbers []uint64
+			for seed := uint64(0); ; seed++ {
+				spin := testBigSlots(t).spin(stake, newBigSlotsRng(seed), true)
+				if spin.Scatters < bigSlotsTriggerScatters {
+					numbers = []uint64{seed}
+					break
+				}
+			}
+
+			result, err := testBigSlots(t).ContinuePlaying(testGameState(t, stake, tt.state), requests.ContinueGame{}, numbers)
+			if tt.fails {
+				if err == nil {
+					t.Fatal("step was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Finished != tt.finished {
+				t.Errorf("finished = %v, want %v", result.Finished, tt.finished)
+			}
+
+			state := BigSlotsState{}
+			if err := json.Unmarshal([]byte(result.Data), &state); err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.capped && !state.TotalWin.Equal(stake.Mul(testBigSlots(t).MaxWin)):
+				t.Errorf("total win = %s, want the max win", state.TotalWin)
+			case !tt.capped && state.TotalWin.LessThan(tt.state.TotalWin):
+				t.Errorf("total win went down to %s", state.TotalWin)
+			}
+			if tt.finished && !result.TotalProfit.Equal(state.TotalWin) {
+				t.Errorf("profit = %s, want the total win %s", result.TotalProfit, state.TotalWin)
+			}
+			if !tt.finished && !result.TotalProfit.IsZero() {
+				t.Errorf("profit = %s before the game finished", result.TotalProfit)
+			}
+		})
+	}
+}
+
+// bigSlotsGame plays a game to the end and returns what it paid.
+func bigSlotsGame(t *testing.T, g *BigSlots, bet requests.Bet, seed uint64) decimal.Decimal {
+	t.Helper()
+	numbers := testNumbers(seed, 1000)
+	result, err := g.StartPlaying(bet, numbers[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for step := 1; !result.Finished; step++ {
+		result, err = g.ContinuePlaying(testGameState(t, bet.Amount, json.RawMessage(result.Data)), requests.ContinueGame{}, numbers[step:step+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return result.TotalProfit
+}
+
+// The weights are tuned to a return of about 0.97. The games are played with fixed seeds,
+// so what they pay is exact and a change to the tumbles, the scatters or the random
+// multipliers shows up as a different payout.
+func TestBigSlotsSeededReturn(t *testing.T) {
+	tests := []struct {
+		name  string
+		games uint64
+		bet   requests.Bet
+		paid  decimal.Decimal
+	}{
+		{
+			name:  "base game",
+			games: 20_000,
+			bet:   requests.Bet{Amount: decimal.NewFromInt(10), CoinID: 2, Data: testData(t, BigSlotsData{})},
+			paid:  decimal.RequireFromString("194775.5"),
+		},
+		{
+			name:  "bought free spins",
+			games: 2_000,
+			bet:   requests.Bet{Amount: decimal.NewFromInt(350), CoinID: 2, Data: testData(t, BigSlotsData{BuyFreeSpins: true})},
+			paid:  decimal.NewFromInt(680_916),
+		},
+	}
+
+	g := testBigSlots(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paid := decimal.Zero
+			for game := range tt.games {
+				paid = paid.Add(bigSlotsGame(t, g, tt.bet, game))
+			}
+			if !paid.Equal(tt.paid) {
+				staked := tt.bet.Amount.Mul(decimal.NewFromUint64(tt.games))
+				t.Errorf("paid %s for %s staked, want %s", paid, staked, tt.paid)
+			}
+		})
+	}
+}
+
+func TestBigSlotsMaxMultiplier(t *testing.T) {
+	tests := []struct {
+		name string
+		data BigSlotsData
+		want decimal.Decimal
+	}{
+		{name: "base game", data: BigSlotsData{}, want: decimal.NewFromInt(5000)},
+		{name: "bought free spins", data: BigSlotsData{BuyFreeSpins: true}, want: decimal.NewFromInt(5000).Div(decimal.NewFromInt(BigSlotsBuyCost))},
+	}
+
+	g := testBigSlots(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := g.MaxMultiplier(requests.Bet{Amount: decimal.NewFromInt(350), Data: testData(t, tt.data)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("max multiplier = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package games
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+)
+
+const (
+	BlackjackPhaseInsurance = "insurance"
+	BlackjackPhasePlaying   = "playing"
+	BlackjackPhaseFinished  = "finished"
+)
+
+func init() {
+	RegisterStateful("Blackjack", func() StatefulGameEngine { return &Blackjack{} }, decodeBlackjack)
+}
+
+// BlackjackContinueData is one of hit, stand, double, split or insurance.
+// Insure is only read by insurance, which is asked when the dealer shows an ace.
+type BlackjackContinueData struct {
+	Action string `json:"action"`
+	Insure bool   `json:"insure"`
+}
+
+type BlackjackHand struct {
+	Cards   []Card          `json:"cards"`
+	Stake   decimal.Decimal `json:"stake"`
+	Split   bool            `json:"split"`
+	Doubled bool            `json:"doubled"`
+	Done    bool            `json:"done"`
+	Payout  decimal.Decimal `json:"payout"`
+}
+
+// BlackjackState holds the whole shoe from the start of the game,
+// PublicState hides it and the hole card of the dealer until the game is finished.
+type BlackjackState struct {
+	Phase     string          `json:"phase"`
+	Hands     []BlackjackHand `json:"hands"`
+	Active    int             `json:"active"`
+	Dealer    []Card          `json:"dealer"`
+	Insurance decimal.Decimal `json:"insurance"`
+	Shoe      []Card          `json:"shoe,omitempty"`
+
+	// exhausted is set when a card was drawn from an empty shoe
+	exhausted bool
+}
+
+// Blackjack deals from a shoe of Decks decks shuffled with the numbers of the first step.
+// BlackjackPayout is the profit of a natural, 1.5 for 3:2 and 1.2 for 6:5.
+// Splitting is allowed until the player has MaxHands hands.
+type Blackjack struct {
+	Decks            uint64          `json:"decks"`
+	BlackjackPayout  decimal.Decimal `json:"blackjack_payout"`
+	DealerHitsSoft17 bool            `json:"dealer_hits_soft17"`
+	MaxHands         int             `json:"max_hands"`
+}
+
+func decodeBlackjack(params string, game interface{}) error {
+	g := game.(*Blackjack)
+	if err := json.Unmarshal([]byte(params), g); err != nil {
+		return err
+	}
+	if g.Decks == 0 {
+		g.Decks = 6
+	}
+	if g.BlackjackPayout.IsZero() {
+		g.BlackjackPayout = decimal.NewFromFloat(1.5)
+	}
+	if g.MaxHands == 0 {
+		g.MaxHands = 4
+	}
+	if g.BlackjackPayout.IsNegative() || g.MaxHands < 1 {
+		return errors.New("Bad blackjack rules")
+	}
+	return nil
+}
+
+func (g *Blackjack) StartPlaying(bet requests.Bet, randomNumbers []uint64) (db.GameResult, error) {
+	// Fisher-Yates shuffle of the whole shoe
+	shoe := make([]Card, 0, g.Decks*52)
+	for range g.Decks {
+		for suit := range uint8(4) {
+			for number := uint8(1); number <= 13; number++ {
+				shoe = append(shoe, Card{Number: number, Suit: suit})
+			}
+		}
+	}
+	for i := len(shoe) - 1; i > 0; i-- {
+		j := randomNumbers[len(shoe)-1-i] % uint64(i+1)
+		shoe[i], shoe[j] = shoe[j], shoe[i]
+	}
+
+	state := BlackjackState{
+		Phase:     BlackjackPhasePlaying,
+		Hands:     []BlackjackHand{{Stake: bet.Amount, Payout: decimal.Zero}},
+		Insurance: decimal.Zero,
+		Shoe:      shoe,
+	}
+	for range 2 {
+		state.Hands[0].Cards = append(state.Hands[0].Cards, state.draw())
+		state.Dealer = append(state.Dealer, state.draw())
+	}
+
+	if state.Dealer[0].Number == 1 {
+		state.Phase = BlackjackPhaseInsurance
+		return blackjackResult(state, decimal.Zero)
+	}
+	g.peek(&state)
+	return g.next(state, decimal.Zero)
+}
+
+func (g *Blackjack) ContinuePlaying(state db.GameState, bet requests.ContinueGame, randomNumbers []uint64) (db.GameResult, error) {
+	data := BlackjackContinueData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	parsedState := BlackjackState{}
+	err = json.Unmarshal([]byte(state.State), &parsedState)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+
+	if parsedState.Phase == BlackjackPhaseInsurance {
+		if data.Action != "insurance" {
+			return db.GameResult{}, errors.New("Insurance must be taken or declined first")
+		}
+		debit := decimal.Zero
+		if data.Insure {
+			debit = parsedState.Hands[0].Stake.Div(decimal.NewFromInt(2))
+			parsedState.Insurance = debit
+		}
+		parsedState.Phase = BlackjackPhasePlaying
+		g.peek(&parsedState)
+		return g.next(parsedState, debit)
+	}
+	if parsedState.Phase != BlackjackPhasePlaying || parsedState.Active >= len(parsedState.Hands) {
+		return db.GameResult{}, errors.New("Game is finished")
+	}
+
+	hand := &parsedState.Hands[parsedState.Active]
+	debit := decimal.Zero
+	switch data.Action {
+	case "hit":
+		hand.Cards = append(hand.Cards, parsedState.draw())
+		if total, _ := blackjackTotal(hand.Cards); total >= 21 {
+			hand.Done = true
+		}
+	case "stand":
+		hand.Done = true
+	case "double":
+		if len(hand.Cards) != 2 {
+			return db.GameResult{}, errors.New("Only the first two cards can be doubled")
+		}
+		debit = hand.Stake
+		hand.Stake = hand.Stake.Add(debit)
+		hand.Doubled = true
+		hand.Cards = append(hand.Cards, parsedState.draw())
+		hand.Done = true
+	case "split":
+		if len(hand.Cards) != 2 || blackjackValue(hand.Cards[0]) != blackjackValue(hand.Cards[1]) {
+			return db.GameResult{}, errors.New("Only a pair can be split")
+		}
+		if len(parsedState.Hands) >= g.MaxHands {
+			return db.GameResult{}, fmt.Errorf("Hands can't be split to more than %d hands", g.MaxHands)
+		}
+		debit = hand.Stake
+		aces := hand.Cards[0].Number == 1
+		split := BlackjackHand{
+			Cards:  []Card{hand.Cards[1], parsedState.draw()},
+			Stake:  hand.Stake,
+			Split:  true,
+			Payout: decimal.Zero,
+		}
+		hand.Cards = []Card{hand.Cards[0], parsedState.draw()}
+		hand.Split = true
+
+		// split aces get a single card each
+		for _, h := range []*BlackjackHand{hand, &split} {
+			if total, _ := blackjackTotal(h.Cards); aces || total == 21 {
+				h.Done = true
+			}
+		}
+		parsedState.Hands = append(parsedState.Hands, BlackjackHand{})
+		copy(parsedState.Hands[parsedState.Active+2:], parsedState.Hands[parsedState.Active+1:])
+		parsedState.Hands[parsedState.Active+1] = split
+	default:
+		return db.GameResult{}, fmt.Errorf("Unknown action %q", data.Action)
+	}
+	return g.next(parsedState, debit)
+}
+
+// Resolve declines the insurance and stands on every hand that is still played.
+func (g *Blackjack) Resolve(state db.GameState) (db.GameResult, error) {
+	parsedState := BlackjackState{}
+	err := json.Unmarshal([]byte(state.State), &parsedState)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+
+	if parsedState.Phase == BlackjackPhaseInsurance {
+		parsedState.Phase = BlackjackPhasePlaying
+		g.peek(&parsedState)
+	}
+	for i := range parsedState.Hands {
+		parsedState.Hands[i].Done = true
+	}
+	return g.next(parsedState, decimal.Zero)
+}
+
+// PublicState hides the shoe and the hole card of the dealer.
+func (g *Blackjack) PublicState(state string) (string, error) {
+	parsedState := BlackjackState{}
+	err := json.Unmarshal([]byte(state), &parsedState)
+	if err != nil {
+		return "", err
+	}
+	parsedState.Shoe = nil
+	if parsedState.Phase != BlackjackPhaseFinished && len(parsedState.Dealer) > 1 {
+		parsedState.Dealer = parsedState.Dealer[:1]
+	}
+
+	stringState, err := json.Marshal(parsedState)
+	return string(stringState), err
+}
+
+// peek ends the game right away when either the dealer or the player has a natural.
+func (g *Blackjack) peek(state *BlackjackState) {
+	if blackjackNatural(state.Dealer) || blackjackNatural(state.Hands[0].Cards) {
+		state.Hands[0].Done = true
+	}
+}
+
+// next moves to the next hand that isn't done, once every hand is done the dealer plays
+// and the game is paid.
+func (g *Blackjack) next(state BlackjackState, debit decimal.Decimal) (db.GameResult, error) {
+	for state.Active < len(state.Hands) && state.Hands[state.Active].Done {
+		state.Active++
+	}
+	if state.exhausted {
+		return db.GameResult{}, errors.New("Shoe is empty")
+	}
+	if state.Active < len(state.Hands) {
+		result, err := blackjackResult(state, decimal.Zero)
+		result.Debit = debit
+		return result, err
+	}
+
+	// the dealer only draws when some hand can still beat the dealer
+	dealerNatural := blackjackNatural(state.Dealer)
+	playing := false
+	for _, hand := range state.Hands {
+		if total, _ := blackjackTotal(hand.Cards); total <= 21 && !blackjackHandNatural(hand) {
+			playing = true
+		}
+	}
+	for playing && !dealerNatural {
+		total, soft := blackjackTotal(state.Dealer)
+		if total > 17 || (total == 17 && (!soft || !g.DealerHitsSoft17)) {
+			break
+		}
+		state.Dealer = append(state.Dealer, state.draw())
+	}
+	if state.exhausted {
+		return db.GameResult{}, errors.New("Shoe is empty")
+	}
+
+	dealer, _ := blackjackTotal(state.Dealer)
+	total := decimal.Zero
+	for i := range state.Hands {
+		hand := &state.Hands[i]
+		player, _ := blackjackTotal(hand.Cards)
+		switch {
+		case blackjackHandNatural(*hand) && dealerNatural:
+			hand.Payout = hand.Stake
+		case blackjackHandNatural(*hand):
+			hand.Payout = hand.Stake.Mul(g.BlackjackPayout.Add(decimal.NewFromInt(1)))
+		case player > 21 || dealerNatural:
+			hand.Payout = decimal.Zero
+		case dealer > 21 || player > dealer:
+			hand.Payout = hand.Stake.Mul(decimal.NewFromInt(2))
+		case player == dealer:
+			hand.Payout = hand.Stake
+		default:
+			hand.Payout = decimal.Zero
+		}
+		total = total.Add(hand.Payout)
+	}
+	insurance := decimal.Zero
+	if dealerNatural {
+		// insurance pays 2:1
+		insurance = state.Insurance.Mul(decimal.NewFromInt(3))
+	}
+
+	state.Phase = BlackjackPhaseFinished
+	state.Shoe = nil
+	result, err := blackjackResult(state, insurance)
+	result.Debit = debit
+	return result, err
+}
+
+// blackjackHandNatural is a natural that wasn't made by splitting.
+func blackjackHandNatural(hand BlackjackHand) bool {
+	return !hand.Split && blackjackNatural(hand.Cards)
+}
+
+// blackjackResult pays every hand and the insurance once the game is finished,
+// the outcomes are the totals of the hands followed by the total of the dealer.
+func blackjackResult(state BlackjackState, insurance decimal.Decimal) (db.GameResult, error) {
+	stringState, err := json.Marshal(state)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+
+	if state.Phase != BlackjackPhaseFinished {
+		return db.GameResult{
+			TotalProfit: decimal.Zero,
+			Outcomes:    []uint64{},
+			Profits:     []decimal.Decimal{},
+			NumGames:    1,
+			Data:        string(stringState),
+			Finished:    false,
+		}, nil
+	}
+
+	totalProfit := decimal.Zero
+	outcomes := []uint64{}
+	profits := []decimal.Decimal{}
+	for _, hand := range state.Hands {
+		total, _ := blackjackTotal(hand.Cards)
+		outcomes = append(outcomes, total)
+		profits = append(profits, hand.Payout)
+		totalProfit = totalProfit.Add(hand.Payout)
+	}
+	dealer, _ := blackjackTotal(state.Dealer)
+	outcomes = append(outcomes, dealer)
+	if state.Insurance.IsPositive() {
+		profits = append(profits, insurance)
+		totalProfit = totalProfit.Add(insurance)
+	}
+
+	return db.GameResult{
+		TotalProfit: totalProfit,
+		Outcomes:    outcomes,
+		Profits:     profits,
+		NumGames:    1,
+		Data:        string(stringState),
+		Finished:    true,
+	}, nil
+}
+
+// draw takes the top card of the shoe, an empty shoe is reported by next.
+func (s *BlackjackState) draw() Card {
+	if len(s.Shoe) == 0 {
+		s.exhausted = true
+		return Card{}
+	}
+	card := s.Shoe[0]
+	s.Shoe = s.Shoe[1:]
+	return card
+}
+
+func blackjackValue(card Card) uint64 {
+	if card.Number == 1 {
+		return 11
+	}
+	return min(uint64(card.Number), 10)
+}
+
+// blackjackTotal counts aces as 1 when 11 would bust the hand, soft is set
+// when an ace is still counted as 11.
+func blackjackTotal(cards []Card) (total uint64, soft bool) {
+	aces := 0
+	for _, card := range cards {
+		total += blackjackValue(card)
+		if card.Number == 1 {
+			aces++
+		}
+	}
+	for total > 21 && aces > 0 {
+		total -= 10
+		aces--
+	}
+	return total, aces > 0
+}
+
+func blackjackNatural(cards []Card) bool {
+	total, _ := blackjackTotal(cards)
+	return len(cards) == 2 && total == 21
+}
+
+// MaxMultiplier is every hand split and doubled.
+func (g *Blackjack) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	return decimal.NewFromInt(int64(4 * g.MaxHands)), nil
+}
+
+// NumbersPerBet covers the shuffle of the shoe, later steps deal from it.
+func (g *Blackjack) NumbersPerBet() uint64 {
+	return g.Decks*52 - 1
+}
+
+// NumbersPerStep is zero, the cards of a continue step come from the shoe in the state.
+func (*Blackjack) NumbersPerStep() uint64 {
+	return 0
+}
@@ -0,0 +1,347 @@
+package games
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/requests"
+)
+
+func testBlackjack() *Blackjack {
+	return &Blackjack{Decks: 2, BlackjackPayout: decimal.RequireFromString("1.5"), MaxHands: 2}
+}
+
+// blackjackCards are cards of the given numbers, the suits don't matter.
+func blackjackCards(numbers ...uint8) []Card {
+	cards := make([]Card, len(numbers))
+	for i, number := range numbers {
+		cards[i] = Card{Number: number}
+	}
+	return cards
+}
+
+func blackjackState(phase string, player []Card, dealer []Card, shoe ...uint8) BlackjackState {
+	return BlackjackState{
+		Phase:     phase,
+		Hands:     []BlackjackHand{{Cards: player, Stake: decimal.NewFromInt(10), Payout: decimal.Zero}},
+		Dealer:    dealer,
+		Insurance: decimal.Zero,
+		Shoe:      blackjackCards(shoe...),
+	}
+}
+
+func TestNumbersPerStep(t *testing.T) {
+	tests := []struct {
+		name  string
+		game  StatefulGameEngine
+		start uint64
+		step  uint64
+	}{
+		{name: "blackjack shuffles the shoe only at the start", game: testBlackjack(), start: 103, step: 0},
+		{name: "mines draws the same amount on every step", game: &Mines{}, start: MinesTiles - 1, step: MinesTiles - 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.game.NumbersPerBet(); got != tt.start {
+				t.Errorf("numbers per bet = %d, want %d", got, tt.start)
+			}
+			if got := NumbersPerStep(tt.game); got != tt.step {
+				t.Errorf("numbers per step = %d, want %d", got, tt.step)
+			}
+		})
+	}
+}
+
+func TestBlackjackContinuePlaying(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    BlackjackState
+		data     BlackjackContinueData
+		fails    bool
+		finished bool
+		profit   decimal.Decimal
+		debit    decimal.Decimal
+	}{
+		{
+			name:     "stand beats the dealer",
+			state:    blackjackState(BlackjackPhasePlaying, blackjackCards(10, 10), blackjackCards(10, 7)),
+			data:     BlackjackContinueData{Action: "stand"},
+			finished: true,
+			profit:   decimal.NewFromInt(20),
+		},
+		{
+			name:     "push returns the stake",
+			state:    blackjackState(BlackjackPhasePlaying, blackjackCards(10, 8), blackjackCards(10, 8)),
+			data:     BlackjackContinueData{Action: "stand"},
+			finished: true,
+			profit:   decimal.NewFromInt(10),
+		},
+		{
+			name:     "dealer draws to 17 and busts",
+			state:    blackjackState(BlackjackPhasePlaying, blackjackCards(10, 2), blackjackCards(10, 6), 10),
+			data:     BlackjackContinueData{Action: "stand"},
+			finished: true,
+			profit:   decimal.NewFromInt(20),
+		},
+		{
+			name:     "hit busts the hand",
+			state:    blackjackState(BlackjackPhasePlaying, blackjackCards(10, 6), blackjackCards(10, 7), 10),
+			data:     BlackjackContinueData{Action: "hit"},
+			finished: true,
+			profit:   decimal.Zero,
+		},
+		{
+			name:   "hit keeps the hand going",
+			state:  blackjackState(BlackjackPhasePlaying, blackjackCards(5, 3), blackjackCards(10, 7), 2),
+			data:   BlackjackContinueData{Action: "hit"},
+			profit: decimal.Zero,
+		},
+		{
+			name:     "double takes another stake and a single card",
+			state:    blackjackState(BlackjackPhasePlaying, blackjackCards(5, 6), blackjackCards(10, 7), 10),
+			data:     BlackjackContinueData{Action: "double"},
+			finished: true,
+			profit:   decimal.NewFromInt(40),
+			debit:    decimal.NewFromInt(10),
+		},
+		{
+			name:   "split takes another stake",
+			state:  blackjackState(BlackjackPhasePlaying, blackjackCards(8, 8), blackjackCards(10, 7), 3, 10),
+			data:   BlackjackContinueData{Action: "split"},
+			profit: decimal.Zero,
+			debit:  decimal.NewFromInt(10),
+		},
+		{
+			name:  "double after the first two cards",
+			state: blackjackState(BlackjackPhasePlaying, blackjackCards(2, 3, 4), blackjackCards(10, 7)),
+			data:  BlackjackContinueData{Action: "double"},
+			fails: true,
+		},
+		{
+			name:  "split of different cards",
+			state: blackjackState(BlackjackPhasePlaying, blackjackCards(8, 9), blackjackCards(10, 7)),
+			data:  BlackjackContinueData{Action: "split"},
+			fails: true,
+		},
+		{
+			name: "split past the max hands",
+			state: BlackjackState{
+				Phase: BlackjackPhasePlaying,
+				Hands: []BlackjackHand{
+					{Cards: blackjackCards(8, 8), Stake: decimal.NewFromInt(10), Split: true},
+					{Cards: blackjackCards(8, 3), Stake: decimal.NewFromInt(10), Split: true},
+				},
+				Dealer: blackjackCards(10, 7),
+				Shoe:   blackjackCards(2, 3),
+			},
+			data:  BlackjackContinueData{Action: "split"},
+			fails: true,
+		},
+		{
+			name:     "insurance pays 2:1 on a dealer natural",
+			state:    blackjackState(BlackjackPhaseInsurance, blackjackCards(10, 9), blackjackCards(1, 13)),
+			data:     BlackjackContinueData{Action: "insurance", Insure: true},
+			finished: true,
+			profit:   decimal.NewFromInt(15),
+			debit:    decimal.NewFromInt(5),
+		},
+		{
+			name:     "declined insurance loses to a dealer natural",
+			state:    blackjackState(BlackjackPhaseInsurance, blackjackCards(10, 9), blackjackCards(1, 13)),
+			data:     BlackjackContinueData{Action: "insurance"},
+			finished: true,
+			profit:   decimal.Zero,
+		},
+		{
+			name:  "insurance is answered first",
+			state: blackjackState(BlackjackPhaseInsurance, blackjackCards(10, 9), blackjackCards(1, 13)),
+			data:  BlackjackContinueData{Action: "stand"},
+			fails: true,
+		},
+		{
+			name:  "empty shoe",
+			state: blackjackState(BlackjackPhasePlaying, blackjackCards(5, 3), blackjackCards(10, 7)),
+			data:  BlackjackContinueData{Action: "hit"},
+			fails: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bet := requests.ContinueGame{Data: testData(t, tt.data)}
+			result, err := testBlackjack().ContinuePlaying(testGameState(t, decimal.NewFromInt(10), tt.state), bet, nil)
+			if tt.fails {
+				if err == nil {
+					t.Fatal("step was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Finished != tt.finished {
+				t.Errorf("finished = %v, want %v", result.Finished, tt.finished)
+			}
+			if !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("profit = %s, want %s", result.TotalProfit, tt.profit)
+			}
+			if !result.Debit.Equal(tt.debit) {
+				t.Errorf("debit = %s, want %s", result.Debit, tt.debit)
+			}
+		})
+	}
+}
+
+func TestBlackjackResolve(t *testing.T) {
+	tests := []struct {
+		name   string
+		state  BlackjackState
+		profit decimal.Decimal
+	}{
+		{
+			name:   "natural pays 3:2",
+			state:  blackjackState(BlackjackPhasePlaying, blackjackCards(1, 13), blackjackCards(10, 7)),
+			profit: decimal.NewFromInt(25),
+		},
+		{
+			name:   "stands on the hand in play",
+			state:  blackjackState(BlackjackPhasePlaying, blackjackCards(10, 4), blackjackCards(10, 6), 10),
+			profit: decimal.NewFromInt(20),
+		},
+		{
+			name:   "declines the insurance",
+			state:  blackjackState(BlackjackPhaseInsurance, blackjackCards(10, 9), blackjackCards(1, 13)),
+			profit: decimal.Zero,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := testBlackjack().Resolve(testGameState(t, decimal.NewFromInt(10), tt.state))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Finished || !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("finished = %v, profit = %s, want %s", result.Finished, result.TotalProfit, tt.profit)
+			}
+		})
+	}
+}
+
+func TestBlackjackPublicState(t *testing.T) {
+	tests := []struct {
+		name   string
+		state  BlackjackState
+		dealer int
+	}{
+		{
+			name:   "hides the hole card while playing",
+			state:  blackjackState(BlackjackPhasePlaying, blackjackCards(10, 4), blackjackCards(10, 6), 10, 2),
+			dealer: 1,
+		},
+		{
+			name:   "shows the dealer once finished",
+			state:  blackjackState(BlackjackPhaseFinished, blackjackCards(10, 4), blackjackCards(10, 6, 10)),
+			dealer: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := testGameState(t, decimal.NewFromInt(10), tt.state).State
+			public, err := testBlackjack().PublicState(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed := BlackjackState{}
+			if err := json.Unmarshal([]byte(public), &parsed); err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Shoe != nil {
+				t.Error("shoe is visible")
+			}
+			if len(parsed.Dealer) != tt.dealer {
+				t.Errorf("dealer shows %d cards, want %d", len(parsed.Dealer), tt.dealer)
+			}
+		})
+	}
+}
+
+func TestBlackjackStartDealsFromTheShoe(t *testing.T) {
+	g := testBlackjack()
+	bet := requests.Bet{Amount: decimal.NewFromInt(10)}
+	result, err := g.StartPlaying(bet, testNumbers(7, int(g.NumbersPerBet())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := BlackjackState{}
+	if err := json.Unmarshal([]byte(result.Data), &state); err != nil {
+		t.Fatal(err)
+	}
+	if result.Finished {
+		// a natural finishes the game and empties the shoe
+		return
+	}
+
+	counts := map[uint8]int{}
+	for _, cards := range [][]Card{state.Shoe, state.Hands[0].Cards, state.Dealer} {
+		for _, card := range cards {
+			counts[card.Number]++
+		}
+	}
+	for number := uint8(1); number <= 13; number++ {
+		if counts[number] != int(g.Decks)*4 {
+			t.Errorf("card %d is in the game %d times, want %d", number, counts[number], g.Decks*4)
+		}
+	}
+}
+
+// Playing like the dealer, hitting below 17, is a known strategy with a house edge of about 5.5%.
+// The games are dealt with fixed seeds from the seeded rules, so the return is exact and a payout
+// or a rule that changes moves it.
+func TestBlackjackDealerStrategyReturn(t *testing.T) {
+	const games = 5_000
+	g := &Blackjack{}
+	if err := decodeBlackjack(`{"decks":6, "blackjack_payout":"1.5", "dealer_hits_soft17":false, "max_hands":4}`, g); err != nil {
+		t.Fatal(err)
+	}
+	stake := decimal.NewFromInt(10)
+
+	staked := decimal.Zero
+	paid := decimal.Zero
+	for game := range uint64(games) {
+		result, err := g.StartPlaying(requests.Bet{Amount: stake}, testNumbers(game, int(g.NumbersPerBet())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		staked = staked.Add(stake)
+
+		for !result.Finished {
+			state := BlackjackState{}
+			if err := json.Unmarshal([]byte(result.Data), &state); err != nil {
+				t.Fatal(err)
+			}
+			data := BlackjackContinueData{Action: "stand"}
+			if state.Phase == BlackjackPhaseInsurance {
+				data = BlackjackContinueData{Action: "insurance"}
+			} else if total, _ := blackjackTotal(state.Hands[state.Active].Cards); total < 17 {
+				data = BlackjackContinueData{Action: "hit"}
+			}
+
+			gameState := testGameState(t, stake, state)
+			result, err = g.ContinuePlaying(gameState, requests.ContinueGame{Data: testData(t, data)}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			staked = staked.Add(result.Debit)
+		}
+		paid = paid.Add(result.TotalProfit)
+	}
+
+	// the strategy never doubles nor insures, every game stakes 10
+	if !staked.Equal(decimal.NewFromInt(50_000)) || !paid.Equal(decimal.NewFromInt(47_705)) {
+		t.Errorf("paid %s for %s staked, want 47705 for 50000", paid, staked)
+	}
+}
@@ -8,6 +8,10 @@ import (
 	"greekkeepers.io/backend/requests"
 )
 
+func init() {
+	RegisterStateless("CoinFlip", func() StatelessGameEngine { return &CoinFlip{} }, JSONParams)
+}
+
 type CoinFlipData struct {
 	IsHeads bool `json:"is_heads"`
 }
@@ -64,6 +68,10 @@ func (g *CoinFlip) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult
 	}, nil
 }
 
+func (g *CoinFlip) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	return g.ProfitCoef, nil
+}
+
 func (*CoinFlip) NumbersPerBet() uint64 {
 	return 1
 }
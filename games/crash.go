@@ -0,0 +1,96 @@
+package games
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CrashName is the name of the Crash game in the games table.
+// Crash is played in shared rounds by engine.CrashEngine, it isn't registered
+// with the stateless or stateful engines.
+const CrashName = "Crash"
+
+// crashGrowth is how fast the multiplier rises, it doubles roughly every 11.5 seconds.
+const crashGrowth = 0.00006
+
+type CrashParams struct {
+	HouseEdge decimal.Decimal `json:"house_edge"`
+	// BettingWindow is how long bets are taken before a round starts, in seconds
+	BettingWindow uint64          `json:"betting_window"`
+	MaxMultiplier decimal.Decimal `json:"max_multiplier"`
+}
+
+// ParseCrashParams reads the parameters of the Crash game, missing values get the defaults.
+func ParseCrashParams(params string) (CrashParams, error) {
+	parsed := CrashParams{}
+	if err := json.Unmarshal([]byte(params), &parsed); err != nil {
+		return CrashParams{}, err
+	}
+
+	if parsed.HouseEdge.IsZero() {
+		parsed.HouseEdge = decimal.NewFromFloat(0.01)
+	}
+	if parsed.BettingWindow == 0 {
+		parsed.BettingWindow = 10
+	}
+	if parsed.MaxMultiplier.IsZero() {
+		parsed.MaxMultiplier = decimal.NewFromInt(1000)
+	}
+	if parsed.HouseEdge.IsNegative() || parsed.HouseEdge.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+		return CrashParams{}, errors.New("house_edge must be between 0 and 1")
+	}
+	if parsed.MaxMultiplier.LessThan(decimal.NewFromInt(1)) {
+		return CrashParams{}, errors.New("max_multiplier must be at least 1")
+	}
+	return parsed, nil
+}
+
+// CrashChainHash returns the hash at position of a chain of length hashes built from seed.
+// Every hash is the sha256 of the hash after it, so rounds walk the chain from position 1
+// to length and the published position 0 commits to all of them in advance.
+func CrashChainHash(seed string, length uint64, position uint64) string {
+	hash := seed
+	for range length - position {
+		hash = PreviousCrashHash(hash)
+	}
+	return hash
+}
+
+// PreviousCrashHash returns the hash one position before hash in its chain.
+func PreviousCrashHash(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return hex.EncodeToString(sum[:])
+}
+
+// CrashPoint derives the multiplier a round crashes at from its hash.
+// The first 52 bits of the hash make a uniform h in [0, 2^52) and the point is
+// (1 - house edge) * 2^52 / (2^52 - h) floored to two decimals, points below 1 crash at 1.
+func CrashPoint(hash string, params CrashParams) (decimal.Decimal, error) {
+	if len(hash) < 13 {
+		return decimal.Zero, errors.New("crash hash is too short")
+	}
+	h, err := strconv.ParseUint(hash[:13], 16, 64)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	e := decimal.NewFromUint64(1 << 52)
+	point := decimal.NewFromInt(1).Sub(params.HouseEdge).
+		Mul(e).
+		Div(e.Sub(decimal.NewFromUint64(h))).
+		RoundFloor(2)
+
+	return decimal.Min(decimal.Max(point, decimal.NewFromInt(1)), params.MaxMultiplier), nil
+}
+
+// CrashMultiplier is the multiplier of a running round elapsed after it started.
+func CrashMultiplier(elapsed time.Duration) decimal.Decimal {
+	return decimal.NewFromFloat(math.Exp(crashGrowth * float64(elapsed.Milliseconds()))).RoundFloor(2)
+}
@@ -0,0 +1,138 @@
+package games
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func testCrashParams(t *testing.T) CrashParams {
+	t.Helper()
+	params, err := ParseCrashParams(`{"house_edge":"0.01", "betting_window":10, "max_multiplier":"1000"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return params
+}
+
+// Every hash of the chain hashes to the one before it, so the published commitment
+// at position 0 is reached from any round and the last position is the seed itself.
+func TestCrashChainCommitment(t *testing.T) {
+	const seed = "c0ffee"
+	const length = 50
+	commitment := CrashChainHash(seed, length, 0)
+
+	if got := CrashChainHash(seed, length, length); got != seed {
+		t.Errorf("last hash = %s, want the seed", got)
+	}
+	for position := uint64(1); position <= length; position++ {
+		hash := CrashChainHash(seed, length, position)
+		if previous := PreviousCrashHash(hash); previous != CrashChainHash(seed, length, position-1) {
+			t.Fatalf("hash %d doesn't hash to the one before it", position)
+		}
+
+		walked := hash
+		for range position {
+			walked = PreviousCrashHash(walked)
+		}
+		if walked != commitment {
+			t.Fatalf("hash %d doesn't lead to the commitment", position)
+		}
+	}
+	if CrashChainHash("c0fffe", length, 0) == commitment {
+		t.Error("another seed has the same commitment")
+	}
+}
+
+func TestCrashPoint(t *testing.T) {
+	tests := []struct {
+		name  string
+		hash  string
+		point string
+	}{
+		{name: "lowest hash crashes at 1", hash: "0000000000000" + strings.Repeat("f", 51), point: "1"},
+		{name: "half of the range", hash: "8000000000000", point: "1.98"},
+		{name: "three quarters of the range", hash: "c000000000000", point: "3.96"},
+		{name: "only the first 52 bits count", hash: "c000000000000fff", point: "3.96"},
+		{name: "points are floored", hash: "aaaaaaaaaaaaa", point: "2.96"},
+		{name: "highest hash is capped", hash: "fffffffffffff", point: "1000"},
+	}
+
+	params := testCrashParams(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			point, err := CrashPoint(tt.hash, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := decimal.RequireFromString(tt.point); !point.Equal(want) {
+				t.Errorf("crash point = %s, want %s", point, want)
+			}
+		})
+	}
+
+	for _, hash := range []string{"abc", "zzzzzzzzzzzzz"} {
+		if _, err := CrashPoint(hash, params); err == nil {
+			t.Errorf("crash point of %q", hash)
+		}
+	}
+}
+
+// A round reaches x with a probability of (1 - house edge) / x.
+func TestCrashPointDistribution(t *testing.T) {
+	const rounds = 20_000
+	params := testCrashParams(t)
+	targets := []struct {
+		multiplier int64
+		reached    int
+	}{{multiplier: 2}, {multiplier: 10}}
+
+	hash := "distribution"
+	for range rounds {
+		hash = PreviousCrashHash(hash)
+		point, err := CrashPoint(hash, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range targets {
+			if point.GreaterThanOrEqual(decimal.NewFromInt(targets[i].multiplier)) {
+				targets[i].reached++
+			}
+		}
+	}
+
+	for _, target := range targets {
+		got := float64(target.reached) / rounds
+		want := 0.99 / float64(target.multiplier)
+		if got < want*0.95 || got > want*1.05 {
+			t.Errorf("%dx reached in %.4f of the rounds, want about %.4f", target.multiplier, got, want)
+		}
+	}
+}
+
+func TestParseCrashParams(t *testing.T) {
+	params, err := ParseCrashParams(`{}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !params.HouseEdge.Equal(decimal.RequireFromString("0.01")) || params.BettingWindow != 10 || !params.MaxMultiplier.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("defaults = %+v", params)
+	}
+
+	for _, bad := range []string{`{"house_edge":"1"}`, `{"house_edge":"-0.1"}`, `{"max_multiplier":"0.5"}`, `[]`} {
+		if _, err := ParseCrashParams(bad); err == nil {
+			t.Errorf("%s was accepted", bad)
+		}
+	}
+}
+
+func TestCrashMultiplier(t *testing.T) {
+	if got := CrashMultiplier(0); !got.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("multiplier at the start = %s", got)
+	}
+	if got := CrashMultiplier(11552 * time.Millisecond); !got.Equal(decimal.RequireFromString("1.99")) {
+		t.Errorf("multiplier after 11.5s = %s, want 1.99", got)
+	}
+}
@@ -25,6 +25,8 @@ func init() {
 	U64_UPPER_BOUNDARY, _ = decimal.NewFromString("18446744073709551615")
 	HUNDRED, _ = decimal.NewFromString("100")
 	NINTYNINE, _ = decimal.NewFromString("99")
+
+	RegisterStateless("Dice", func() StatelessGameEngine { return &Dice{} }, JSONParams)
 }
 
 type DiceData struct {
@@ -115,6 +117,15 @@ func (g *Dice) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult, er
 	}, nil
 }
 
+func (g *Dice) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	data := DiceData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return data.Multiplier, nil
+}
+
 func (*Dice) NumbersPerBet() uint64 {
 	return 1
 }
@@ -1,6 +1,7 @@
 package games
 
 import (
+	"github.com/shopspring/decimal"
 	"greekkeepers.io/backend/db"
 	"greekkeepers.io/backend/requests"
 )
@@ -14,3 +15,37 @@ type StatefulGameEngine interface {
 	ContinuePlaying(state db.GameState, bet requests.ContinueGame, randomNumbers []uint64) (db.GameResult, error)
 	NumbersPerBet() uint64
 }
+
+// SteppedGame is implemented by stateful games whose continue steps draw a different
+// amount of random numbers than the start, like Blackjack that deals from the shoe it shuffled.
+type SteppedGame interface {
+	NumbersPerStep() uint64
+}
+
+// NumbersPerStep is the amount of random numbers a continue step of the game is played with.
+func NumbersPerStep(game StatefulGameEngine) uint64 {
+	if stepped, ok := game.(SteppedGame); ok {
+		return stepped.NumbersPerStep()
+	}
+	return game.NumbersPerBet()
+}
+
+// ResolvableGame is implemented by stateful games that can finish a game
+// the player abandoned. Resolve gets the state the game was left in and ends it the way
+// the player could have without taking another risk: the stake is returned when nothing
+// was played yet, otherwise the game is cashed out or stood on.
+type ResolvableGame interface {
+	Resolve(state db.GameState) (db.GameResult, error)
+}
+
+// BoundedGame is implemented by games that know the highest multiplier a single game
+// of the bet can pay, the engine uses it to keep bets within the bankroll exposure.
+type BoundedGame interface {
+	MaxMultiplier(bet requests.Bet) (decimal.Decimal, error)
+}
+
+// MaskedGame is implemented by stateful games whose state holds something the player
+// must not see before the game is finished. PublicState returns the state as it's sent out.
+type MaskedGame interface {
+	PublicState(state string) (string, error)
+}
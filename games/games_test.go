@@ -0,0 +1,42 @@
+package games
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+)
+
+// testGameState stores state the way the stateful engine does.
+func testGameState(t *testing.T, amount decimal.Decimal, state interface{}) db.GameState {
+	t.Helper()
+	raw, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db.GameState{Amount: amount, State: string(raw)}
+}
+
+// testNumbers are the random numbers of a splitmix64 seeded with seed,
+// a fixed seed plays the same games on every run.
+func testNumbers(seed uint64, amount int) []uint64 {
+	numbers := make([]uint64, amount)
+	for i := range numbers {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		numbers[i] = z ^ (z >> 31)
+	}
+	return numbers
+}
+
+func testData(t *testing.T, data interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
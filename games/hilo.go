@@ -0,0 +1,205 @@
+package games
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+)
+
+func init() {
+	RegisterStateful("HiLo", func() StatefulGameEngine { return &HiLo{} }, decodeHiLo)
+}
+
+// HiLoContinueData guesses higher, lower or skip, higher and lower include cards of the same number.
+type HiLoContinueData struct {
+	Guess   string `json:"guess"`
+	Cashout bool   `json:"cashout"`
+}
+
+// HiLoStep is a drawn card with the guess it was drawn for, the first card has no guess.
+type HiLoStep struct {
+	Card       Card            `json:"card"`
+	Guess      string          `json:"guess"`
+	Multiplier decimal.Decimal `json:"multiplier"`
+}
+
+type HiLoState struct {
+	History           []HiLoStep      `json:"history"`
+	CurrentMultiplier decimal.Decimal `json:"current_multiplier"`
+}
+
+// HiLo draws every card from the full InitialDeck, so the odds of a guess only depend on the shown card.
+// A correct guess multiplies the current multiplier by its exact odds minus Edge,
+// the game is cashed out once MaxWin is reached.
+type HiLo struct {
+	InitialDeck []Card          `json:"initial_deck"`
+	Edge        decimal.Decimal `json:"edge"`
+	MaxWin      decimal.Decimal `json:"max_win"`
+}
+
+func decodeHiLo(params string, game interface{}) error {
+	g := game.(*HiLo)
+	if err := json.Unmarshal([]byte(params), g); err != nil {
+		return err
+	}
+	if len(g.InitialDeck) == 0 {
+		for suit := range uint8(4) {
+			for number := uint8(1); number <= 13; number++ {
+				g.InitialDeck = append(g.InitialDeck, Card{Number: number, Suit: suit})
+			}
+		}
+	}
+	if g.MaxWin.IsZero() {
+		g.MaxWin = decimal.NewFromInt(1000)
+	}
+	if g.Edge.IsNegative() || g.Edge.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+		return errors.New("Edge must be between 0 and 1")
+	}
+	return nil
+}
+
+func (g *HiLo) StartPlaying(bet requests.Bet, randomNumbers []uint64) (db.GameResult, error) {
+	deck := slices.Clone(g.InitialDeck)
+	state := HiLoState{
+		History:           []HiLoStep{{Card: pickCard(randomNumbers[0], &deck), Multiplier: decimal.NewFromInt(1)}},
+		CurrentMultiplier: decimal.NewFromInt(1),
+	}
+	return hiloResult(state, decimal.Zero, false)
+}
+
+func (g *HiLo) ContinuePlaying(state db.GameState, bet requests.ContinueGame, randomNumbers []uint64) (db.GameResult, error) {
+	data := HiLoContinueData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	parsedState := HiLoState{}
+	err = json.Unmarshal([]byte(state.State), &parsedState)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	if len(parsedState.History) == 0 {
+		return db.GameResult{}, errors.New("No card was drawn")
+	}
+
+	if data.Cashout {
+		if !g.guessed(parsedState) {
+			return db.GameResult{}, errors.New("Nothing to cash out")
+		}
+		return hiloResult(parsedState, state.Amount.Mul(parsedState.CurrentMultiplier), true)
+	}
+
+	shown := parsedState.History[len(parsedState.History)-1].Card
+	step := decimal.NewFromInt(1)
+	if data.Guess != "skip" {
+		step, err = g.odds(shown, data.Guess)
+		if err != nil {
+			return db.GameResult{}, err
+		}
+	}
+
+	deck := slices.Clone(g.InitialDeck)
+	card := pickCard(randomNumbers[0], &deck)
+
+	won := data.Guess == "skip" ||
+		(data.Guess == "higher" && card.Number >= shown.Number) ||
+		(data.Guess == "lower" && card.Number <= shown.Number)
+	if !won {
+		parsedState.History = append(parsedState.History, HiLoStep{Card: card, Guess: data.Guess, Multiplier: decimal.Zero})
+		parsedState.CurrentMultiplier = decimal.Zero
+		return hiloResult(parsedState, decimal.Zero, true)
+	}
+
+	parsedState.CurrentMultiplier = parsedState.CurrentMultiplier.Mul(step).Truncate(4)
+	parsedState.History = append(parsedState.History, HiLoStep{Card: card, Guess: data.Guess, Multiplier: step})
+	if parsedState.CurrentMultiplier.GreaterThanOrEqual(g.MaxWin) {
+		parsedState.CurrentMultiplier = g.MaxWin
+		return hiloResult(parsedState, state.Amount.Mul(parsedState.CurrentMultiplier), true)
+	}
+	return hiloResult(parsedState, decimal.Zero, false)
+}
+
+// Resolve cashes out an abandoned game at the current multiplier,
+// the stake is returned when nothing was guessed yet.
+func (g *HiLo) Resolve(state db.GameState) (db.GameResult, error) {
+	parsedState := HiLoState{}
+	err := json.Unmarshal([]byte(state.State), &parsedState)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	if !g.guessed(parsedState) {
+		return hiloResult(parsedState, state.Amount, true)
+	}
+	return hiloResult(parsedState, state.Amount.Mul(parsedState.CurrentMultiplier), true)
+}
+
+// guessed reports whether a card was guessed, skipped cards don't count.
+func (g *HiLo) guessed(state HiLoState) bool {
+	for _, step := range state.History {
+		if step.Guess == "higher" || step.Guess == "lower" {
+			return true
+		}
+	}
+	return false
+}
+
+// odds is the multiplier of a correct guess on the shown card, the share of the deck
+// the guess wins with turned around and lowered by the edge.
+func (g *HiLo) odds(shown Card, guess string) (decimal.Decimal, error) {
+	winning := 0
+	for _, card := range g.InitialDeck {
+		if (guess == "higher" && card.Number >= shown.Number) || (guess == "lower" && card.Number <= shown.Number) {
+			winning++
+		}
+	}
+	switch {
+	case guess != "higher" && guess != "lower":
+		return decimal.Zero, fmt.Errorf("Unknown guess %q", guess)
+	case winning == 0:
+		return decimal.Zero, errors.New("Guess can't win")
+	case winning == len(g.InitialDeck):
+		return decimal.Zero, errors.New("Guess can't lose, skip the card instead")
+	}
+
+	return decimal.NewFromInt(1).Sub(g.Edge).
+		Mul(decimal.NewFromInt(int64(len(g.InitialDeck)))).
+		Div(decimal.NewFromInt(int64(winning))), nil
+}
+
+// hiloResult keeps the game going or finishes it with profit, the outcomes are the drawn card numbers.
+func hiloResult(state HiLoState, profit decimal.Decimal, finished bool) (db.GameResult, error) {
+	stringState, err := json.Marshal(state)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+
+	outcomes := make([]uint64, len(state.History))
+	for i, step := range state.History {
+		outcomes[i] = uint64(step.Card.Number)
+	}
+	profits := []decimal.Decimal{}
+	if finished {
+		profits = append(profits, profit)
+	}
+	return db.GameResult{
+		TotalProfit: profit,
+		Outcomes:    outcomes,
+		Profits:     profits,
+		NumGames:    1,
+		Data:        string(stringState),
+		Finished:    finished,
+	}, nil
+}
+
+func (g *HiLo) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	return g.MaxWin, nil
+}
+
+func (*HiLo) NumbersPerBet() uint64 {
+	return 1
+}
@@ -0,0 +1,215 @@
+package games
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/requests"
+)
+
+func testHiLo(t *testing.T) *HiLo {
+	t.Helper()
+	g := &HiLo{}
+	if err := decodeHiLo(`{"edge":"0.01", "max_win":"1000"}`, g); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// hiloState shows a card of number after the given steps, multiplier is the product of the steps.
+func hiloState(number uint8, multiplier string, steps ...HiLoStep) HiLoState {
+	history := append([]HiLoStep{{Multiplier: decimal.NewFromInt(1)}}, steps...)
+	history[len(history)-1].Card = Card{Number: number}
+	return HiLoState{History: history, CurrentMultiplier: decimal.RequireFromString(multiplier)}
+}
+
+// hiloDraw is the random number that draws a card of number from the full deck.
+func hiloDraw(number uint8) []uint64 {
+	return []uint64{uint64(number - 1)}
+}
+
+var hiloGuessed = HiLoStep{Guess: "higher", Multiplier: decimal.RequireFromString("1.8385")}
+
+func TestHiLoContinuePlaying(t *testing.T) {
+	amount := decimal.NewFromInt(10)
+	tests := []struct {
+		name       string
+		state      HiLoState
+		data       HiLoContinueData
+		draw       uint8
+		fails      bool
+		finished   bool
+		profit     decimal.Decimal
+		multiplier decimal.Decimal
+	}{
+		{
+			name:       "higher wins on a higher card",
+			state:      hiloState(7, "1"),
+			data:       HiLoContinueData{Guess: "higher"},
+			draw:       10,
+			profit:     decimal.Zero,
+			multiplier: decimal.RequireFromString("1.8385"),
+		},
+		{
+			name:       "higher wins on the same card",
+			state:      hiloState(7, "1"),
+			data:       HiLoContinueData{Guess: "higher"},
+			draw:       7,
+			profit:     decimal.Zero,
+			multiplier: decimal.RequireFromString("1.8385"),
+		},
+		{
+			name:       "lower loses on a higher card",
+			state:      hiloState(7, "1.8385", hiloGuessed),
+			data:       HiLoContinueData{Guess: "lower"},
+			draw:       10,
+			finished:   true,
+			profit:     decimal.Zero,
+			multiplier: decimal.Zero,
+		},
+		{
+			name:       "skip keeps the multiplier",
+			state:      hiloState(7, "1.8385", hiloGuessed),
+			data:       HiLoContinueData{Guess: "skip"},
+			draw:       2,
+			profit:     decimal.Zero,
+			multiplier: decimal.RequireFromString("1.8385"),
+		},
+		{
+			name:       "cashout pays the current multiplier",
+			state:      hiloState(7, "1.8385", hiloGuessed),
+			data:       HiLoContinueData{Cashout: true},
+			finished:   true,
+			profit:     decimal.RequireFromString("18.385"),
+			multiplier: decimal.RequireFromString("1.8385"),
+		},
+		{
+			name:       "max win cashes out",
+			state:      hiloState(7, "999", hiloGuessed),
+			data:       HiLoContinueData{Guess: "higher"},
+			draw:       13,
+			finished:   true,
+			profit:     decimal.NewFromInt(10_000),
+			multiplier: decimal.NewFromInt(1000),
+		},
+		{
+			name:  "nothing to cash out",
+			state: hiloState(7, "1", HiLoStep{Guess: "skip", Multiplier: decimal.NewFromInt(1)}),
+			data:  HiLoContinueData{Cashout: true},
+			fails: true,
+		},
+		{
+			name:  "guess that can't lose",
+			state: hiloState(1, "1"),
+			data:  HiLoContinueData{Guess: "higher"},
+			fails: true,
+		},
+		{
+			name:  "unknown guess",
+			state: hiloState(7, "1"),
+			data:  HiLoContinueData{Guess: "same"},
+			fails: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bet := requests.ContinueGame{Data: testData(t, tt.data)}
+			result, err := testHiLo(t).ContinuePlaying(testGameState(t, amount, tt.state), bet, hiloDraw(max(tt.draw, 1)))
+			if tt.fails {
+				if err == nil {
+					t.Fatal("step was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Finished != tt.finished {
+				t.Errorf("finished = %v, want %v", result.Finished, tt.finished)
+			}
+			if !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("profit = %s, want %s", result.TotalProfit, tt.profit)
+			}
+
+			state := HiLoState{}
+			if err := json.Unmarshal([]byte(result.Data), &state); err != nil {
+				t.Fatal(err)
+			}
+			if !state.CurrentMultiplier.Equal(tt.multiplier) {
+				t.Errorf("multiplier = %s, want %s", state.CurrentMultiplier, tt.multiplier)
+			}
+		})
+	}
+}
+
+func TestHiLoResolve(t *testing.T) {
+	amount := decimal.NewFromInt(10)
+	tests := []struct {
+		name   string
+		state  HiLoState
+		profit decimal.Decimal
+	}{
+		{
+			name:   "returns the stake of an untouched game",
+			state:  hiloState(7, "1"),
+			profit: amount,
+		},
+		{
+			name:   "returns the stake when every card was skipped",
+			state:  hiloState(7, "1", HiLoStep{Guess: "skip", Multiplier: decimal.NewFromInt(1)}),
+			profit: amount,
+		},
+		{
+			name:   "cashes out a guessed game",
+			state:  hiloState(7, "1.8385", hiloGuessed),
+			profit: decimal.RequireFromString("18.385"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := testHiLo(t).Resolve(testGameState(t, amount, tt.state))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Finished || !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("finished = %v, profit = %s, want %s", result.Finished, result.TotalProfit, tt.profit)
+			}
+		})
+	}
+}
+
+// Every guess on every card must return 1 - edge over the whole deck,
+// the multipliers are only truncated to 4 decimals.
+func TestHiLoReturnToPlayer(t *testing.T) {
+	g := testHiLo(t)
+	rtp := decimal.NewFromInt(1).Sub(g.Edge)
+	for shown := uint8(1); shown <= 13; shown++ {
+		for _, guess := range []string{"higher", "lower"} {
+			if (shown == 1 && guess == "higher") || (shown == 13 && guess == "lower") {
+				continue
+			}
+
+			total := decimal.Zero
+			for draw := range uint64(len(g.InitialDeck)) {
+				bet := requests.ContinueGame{Data: testData(t, HiLoContinueData{Guess: guess})}
+				result, err := g.ContinuePlaying(testGameState(t, decimal.NewFromInt(1), hiloState(shown, "1")), bet, []uint64{draw})
+				if err != nil {
+					t.Fatal(err)
+				}
+				state := HiLoState{}
+				if err := json.Unmarshal([]byte(result.Data), &state); err != nil {
+					t.Fatal(err)
+				}
+				total = total.Add(state.CurrentMultiplier)
+			}
+
+			mean := total.Div(decimal.NewFromInt(int64(len(g.InitialDeck))))
+			if mean.GreaterThan(rtp) || mean.LessThan(rtp.Sub(decimal.RequireFromString("0.0001"))) {
+				t.Errorf("%s on %d returns %s, want %s", guess, shown, mean, rtp)
+			}
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package games
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+)
+
+// MinesTiles is the size of the 5x5 Mines board.
+const MinesTiles = 25
+
+func init() {
+	RegisterStateful("Mines", func() StatefulGameEngine { return &Mines{} }, JSONParams)
+}
+
+type MinesData struct {
+	Mines uint8 `json:"mines"`
+}
+
+type MinesContinueData struct {
+	Tile    uint8 `json:"tile"`
+	Cashout bool  `json:"cashout"`
+}
+
+// MinesState holds the whole layout from the start of the game,
+// PublicState hides it until the game is finished.
+type MinesState struct {
+	Mines             uint8           `json:"mines"`
+	Layout            []bool          `json:"layout,omitempty"`
+	Revealed          []uint8         `json:"revealed"`
+	CurrentMultiplier decimal.Decimal `json:"current_multiplier"`
+}
+
+// Mines has a row of multipliers for every amount of mines, indexed by the amount of revealed tiles.
+// MaxReveal caps how many tiles can be revealed with that amount of mines.
+type Mines struct {
+	MaxReveal   []uint8             `json:"max_reveal"`
+	Multipliers [][]decimal.Decimal `json:"multipliers"`
+}
+
+func (g *Mines) StartPlaying(bet requests.Bet, randomNumbers []uint64) (db.GameResult, error) {
+	data := MinesData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	if err := g.checkMines(data.Mines); err != nil {
+		return db.GameResult{}, err
+	}
+
+	// Fisher-Yates shuffle of the tiles, the first tiles of the shuffle are the mines
+	tiles := make([]uint8, MinesTiles)
+	for i := range tiles {
+		tiles[i] = uint8(i)
+	}
+	for i := MinesTiles - 1; i > 0; i-- {
+		j := randomNumbers[MinesTiles-1-i] % uint64(i+1)
+		tiles[i], tiles[j] = tiles[j], tiles[i]
+	}
+	layout := make([]bool, MinesTiles)
+	for _, tile := range tiles[:data.Mines] {
+		layout[tile] = true
+	}
+
+	returnData, _ := json.Marshal(MinesState{
+		Mines:             data.Mines,
+		Layout:            layout,
+		Revealed:          []uint8{},
+		CurrentMultiplier: decimal.Zero,
+	})
+
+	return db.GameResult{
+		TotalProfit: decimal.Zero,
+		Outcomes:    []uint64{},
+		Profits:     []decimal.Decimal{},
+		NumGames:    uint32(1),
+		Data:        string(returnData),
+		Finished:    false,
+	}, nil
+}
+
+func (g *Mines) ContinuePlaying(state db.GameState, bet requests.ContinueGame, randomNumbers []uint64) (db.GameResult, error) {
+	data := MinesContinueData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	parsedState := MinesState{}
+	err = json.Unmarshal([]byte(state.State), &parsedState)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	if err := g.checkMines(parsedState.Mines); err != nil {
+		return db.GameResult{}, err
+	}
+	if len(parsedState.Layout) != MinesTiles {
+		return db.GameResult{}, errors.New("Bad mines layout")
+	}
+
+	if data.Cashout {
+		if len(parsedState.Revealed) == 0 {
+			return db.GameResult{}, errors.New("Nothing to cash out")
+		}
+		return minesResult(state.Amount.Mul(parsedState.CurrentMultiplier), parsedState)
+	}
+
+	if data.Tile >= MinesTiles {
+		return db.GameResult{}, errors.New("Picked tile is outside of the board")
+	}
+	for _, tile := range parsedState.Revealed {
+		if tile == data.Tile {
+			return db.GameResult{}, errors.New("Tile is already revealed")
+		}
+	}
+	parsedState.Revealed = append(parsedState.Revealed, data.Tile)
+
+	if parsedState.Layout[data.Tile] {
+		parsedState.CurrentMultiplier = decimal.Zero
+		return minesResult(decimal.Zero, parsedState)
+	}
+
+	revealed := len(parsedState.Revealed)
+	parsedState.CurrentMultiplier = g.Multipliers[parsedState.Mines-1][revealed-1]
+	if revealed >= int(g.MaxReveal[parsedState.Mines-1]) || revealed == MinesTiles-int(parsedState.Mines) {
+		return minesResult(state.Amount.Mul(parsedState.CurrentMultiplier), parsedState)
+	}
+
+	stringState, _ := json.Marshal(parsedState)
+	return db.GameResult{
+		TotalProfit: decimal.Zero,
+		Outcomes:    minesOutcomes(parsedState),
+		Profits:     []decimal.Decimal{},
+		NumGames:    1,
+		Data:        string(stringState),
+		Finished:    false,
+	}, nil
+}
+
+// Resolve cashes out an abandoned game at the current multiplier,
+// the stake is returned when no tile was revealed yet.
+func (g *Mines) Resolve(state db.GameState) (db.GameResult, error) {
+	parsedState := MinesState{}
+	err := json.Unmarshal([]byte(state.State), &parsedState)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	if len(parsedState.Revealed) == 0 {
+		return minesResult(state.Amount, parsedState)
+	}
+	return minesResult(state.Amount.Mul(parsedState.CurrentMultiplier), parsedState)
+}
+
+// PublicState hides the layout of the mines.
+func (g *Mines) PublicState(state string) (string, error) {
+	parsedState := MinesState{}
+	err := json.Unmarshal([]byte(state), &parsedState)
+	if err != nil {
+		return "", err
+	}
+	parsedState.Layout = nil
+
+	stringState, err := json.Marshal(parsedState)
+	return string(stringState), err
+}
+
+// minesResult finishes the game, the final state shows the whole layout.
+func minesResult(profit decimal.Decimal, parsedState MinesState) (db.GameResult, error) {
+	stringState, err := json.Marshal(parsedState)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+	return db.GameResult{
+		TotalProfit: profit,
+		Outcomes:    minesOutcomes(parsedState),
+		Profits:     []decimal.Decimal{profit},
+		NumGames:    1,
+		Data:        string(stringState),
+		Finished:    true,
+	}, nil
+}
+
+func minesOutcomes(parsedState MinesState) []uint64 {
+	outcomes := make([]uint64, len(parsedState.Revealed))
+	for i, tile := range parsedState.Revealed {
+		outcomes[i] = uint64(tile)
+	}
+	return outcomes
+}
+
+func (g *Mines) checkMines(mines uint8) error {
+	if mines == 0 || mines >= MinesTiles {
+		return errors.New("Mines must be between 1 and 24")
+	}
+	if int(mines) > len(g.Multipliers) || int(mines) > len(g.MaxReveal) {
+		return errors.New("No multipliers for the amount of mines")
+	}
+	maxReveal := int(g.MaxReveal[mines-1])
+	if maxReveal == 0 || maxReveal > len(g.Multipliers[mines-1]) || maxReveal > MinesTiles-int(mines) {
+		return errors.New("Bad max reveal for the amount of mines")
+	}
+	return nil
+}
+
+func (g *Mines) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	data := MinesData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if err := g.checkMines(data.Mines); err != nil {
+		return decimal.Zero, err
+	}
+
+	multipliers := g.Multipliers[data.Mines-1][:g.MaxReveal[data.Mines-1]]
+	return decimal.Max(multipliers[0], multipliers[1:]...), nil
+}
+
+// NumbersPerBet covers the shuffle of the board, reveals don't use random numbers.
+func (*Mines) NumbersPerBet() uint64 {
+	return MinesTiles - 1
+}
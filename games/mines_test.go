@@ -0,0 +1,269 @@
+package games
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/requests"
+)
+
+func testMines() *Mines {
+	return &Mines{
+		MaxReveal: []uint8{3, 2},
+		Multipliers: [][]decimal.Decimal{
+			{decimal.RequireFromString("1.03"), decimal.RequireFromString("1.08"), decimal.RequireFromString("1.13")},
+			{decimal.RequireFromString("1.08"), decimal.RequireFromString("1.17")},
+		},
+	}
+}
+
+// minesLayout puts the mines on the given tiles.
+func minesLayout(mines ...uint8) []bool {
+	layout := make([]bool, MinesTiles)
+	for _, tile := range mines {
+		layout[tile] = true
+	}
+	return layout
+}
+
+func TestMinesStartPlaying(t *testing.T) {
+	tests := []struct {
+		name  string
+		mines uint8
+		fails bool
+	}{
+		{name: "one mine", mines: 1},
+		{name: "two mines", mines: 2},
+		{name: "no mines", mines: 0, fails: true},
+		{name: "no multipliers for the amount", mines: 3, fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bet := requests.Bet{Amount: decimal.NewFromInt(10), Data: testData(t, MinesData{Mines: tt.mines})}
+			result, err := testMines().StartPlaying(bet, testNumbers(1, MinesTiles-1))
+			if tt.fails {
+				if err == nil {
+					t.Fatal("bet was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state := MinesState{}
+			if err := json.Unmarshal([]byte(result.Data), &state); err != nil {
+				t.Fatal(err)
+			}
+			placed := 0
+			for _, mine := range state.Layout {
+				if mine {
+					placed++
+				}
+			}
+			if placed != int(tt.mines) || result.Finished {
+				t.Errorf("placed %d mines, finished %v", placed, result.Finished)
+			}
+		})
+	}
+}
+
+func TestMinesContinuePlaying(t *testing.T) {
+	amount := decimal.NewFromInt(10)
+	tests := []struct {
+		name     string
+		state    MinesState
+		data     MinesContinueData
+		fails    bool
+		finished bool
+		profit   decimal.Decimal
+	}{
+		{
+			name:   "safe tile raises the multiplier",
+			state:  MinesState{Mines: 1, Layout: minesLayout(0), Revealed: []uint8{}},
+			data:   MinesContinueData{Tile: 5},
+			profit: decimal.Zero,
+		},
+		{
+			name:     "mine loses the stake",
+			state:    MinesState{Mines: 1, Layout: minesLayout(0), Revealed: []uint8{3}, CurrentMultiplier: decimal.RequireFromString("1.03")},
+			data:     MinesContinueData{Tile: 0},
+			finished: true,
+			profit:   decimal.Zero,
+		},
+		{
+			name:     "last allowed reveal cashes out",
+			state:    MinesState{Mines: 2, Layout: minesLayout(0, 1), Revealed: []uint8{3}, CurrentMultiplier: decimal.RequireFromString("1.08")},
+			data:     MinesContinueData{Tile: 4},
+			finished: true,
+			profit:   decimal.RequireFromString("11.7"),
+		},
+		{
+			name:     "cashout pays the current multiplier",
+			state:    MinesState{Mines: 1, Layout: minesLayout(0), Revealed: []uint8{3, 4}, CurrentMultiplier: decimal.RequireFromString("1.08")},
+			data:     MinesContinueData{Cashout: true},
+			finished: true,
+			profit:   decimal.RequireFromString("10.8"),
+		},
+		{
+			name:  "nothing to cash out",
+			state: MinesState{Mines: 1, Layout: minesLayout(0), Revealed: []uint8{}},
+			data:  MinesContinueData{Cashout: true},
+			fails: true,
+		},
+		{
+			name:  "tile revealed twice",
+			state: MinesState{Mines: 1, Layout: minesLayout(0), Revealed: []uint8{3}, CurrentMultiplier: decimal.RequireFromString("1.03")},
+			data:  MinesContinueData{Tile: 3},
+			fails: true,
+		},
+		{
+			name:  "tile outside of the board",
+			state: MinesState{Mines: 1, Layout: minesLayout(0), Revealed: []uint8{}},
+			data:  MinesContinueData{Tile: MinesTiles},
+			fails: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bet := requests.ContinueGame{Data: testData(t, tt.data)}
+			result, err := testMines().ContinuePlaying(testGameState(t, amount, tt.state), bet, nil)
+			if tt.fails {
+				if err == nil {
+					t.Fatal("step was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Finished != tt.finished {
+				t.Errorf("finished = %v, want %v", result.Finished, tt.finished)
+			}
+			if !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("profit = %s, want %s", result.TotalProfit, tt.profit)
+			}
+		})
+	}
+}
+
+func TestMinesResolve(t *testing.T) {
+	amount := decimal.NewFromInt(10)
+	tests := []struct {
+		name   string
+		state  MinesState
+		profit decimal.Decimal
+	}{
+		{
+			name:   "returns the stake of an untouched game",
+			state:  MinesState{Mines: 1, Layout: minesLayout(0), Revealed: []uint8{}},
+			profit: amount,
+		},
+		{
+			name:   "cashes out a played game",
+			state:  MinesState{Mines: 1, Layout: minesLayout(0), Revealed: []uint8{3}, CurrentMultiplier: decimal.RequireFromString("1.03")},
+			profit: decimal.RequireFromString("10.3"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := testMines().Resolve(testGameState(t, amount, tt.state))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Finished || !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("finished = %v, profit = %s, want %s", result.Finished, result.TotalProfit, tt.profit)
+			}
+		})
+	}
+}
+
+func TestMinesPublicState(t *testing.T) {
+	state := testGameState(t, decimal.NewFromInt(10), MinesState{Mines: 1, Layout: minesLayout(7), Revealed: []uint8{3}})
+	public, err := testMines().PublicState(state.State)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := MinesState{}
+	if err := json.Unmarshal([]byte(public), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Layout != nil {
+		t.Error("layout is visible")
+	}
+	if len(parsed.Revealed) != 1 || parsed.Revealed[0] != 3 {
+		t.Errorf("revealed = %v, want [3]", parsed.Revealed)
+	}
+}
+
+// testMinesParams are the seeded parameters, every multiplier is priced at a return of 0.99.
+const testMinesParams = `{"max_reveal":[ 24, 21, 17, 14, 12, 10, 9, 8, 7, 6, 5, 5, 4, 4, 3, 3, 3, 2, 2, 2, 2, 1, 1, 1 ], "multipliers":[["1.0312", "1.076", "1.125", "1.1785", "1.2375", "1.3026", "1.375", "1.4558", "1.5468", "1.65", "1.7678", "1.9038", "2.0625", "2.25", "2.475", "2.75", "3.0937", "3.5357", "4.125", "4.95", "6.1875", "8.25", "12.375", "24.75"], ["1.076", "1.1739", "1.2857", "1.4142", "1.5631", "1.7368", "1.9411", "2.1838", "2.475", "2.8285", "3.2637", "3.8076", "4.5", "5.4", "6.6", "8.25", "10.6071", "14.1428", "19.8", "29.7", "49.5", "99.0", "297.0"], ["1.125", "1.2857", "1.4785", "1.712", "1.9973", "2.3498", "2.7904", "3.3485", "4.066", "5.0043", "6.2554", "7.9615", "10.35", "13.8", "18.975", "27.1071", "40.6607", "65.0571", "113.85", "227.7", "569.2501", "2277.0031"], ["1.1785", "1.4142", "1.712", "2.0924", "2.5848", "3.231", "4.0926", "5.2619", "6.881", "9.1747", "12.5109", "17.5153", "25.3", "37.95", "59.6357", "99.3928", "178.9071", "357.8143", "834.9005", "2504.7058", "12523.5607"], ["1.2375", "1.5631", "1.9973", "2.5848", "3.3925", "4.5234", "6.1389", "8.5001", "12.0418", "17.5153", "26.273", "40.8692", "66.4125", "113.85", "208.725", "417.45", "939.2628", "2504.7058", "8766.4925", "52600.8182"], ["1.3026", "1.7368", "2.3498", "3.231", "4.5234", "6.462", "9.4445", "14.1668", "21.8942", "35.0307", "58.3846", "102.173", "189.75", "379.5", "834.9005", "2087.2513", "6261.7803", "25047.4383", "175345.3772"], ["1.375", "1.9411", "2.7904", "4.0926", "6.1389", "9.4445", "14.9539", "24.47", "41.599", "73.9538", "138.6634", "277.3269", "600.875", "1442.1017", "3965.79", "13219.3884", "59488.0423", "475961.5384"], ["1.4558", "2.1838", "3.3485", "5.2619", "8.5001", "14.1668", "24.47", "44.046", "83.198", "166.3961", "356.5632", "831.981", "2163.1542", "6489.4628", "23795.2169", "118976.0846", "1071428.5714"], ["1.5468", "2.475", "4.066", "6.881", "12.0418", "21.8942", "41.599", "83.198", "176.7959", "404.105", "1010.2628", "2828.7411", "9193.3956", "36774.2654", "202288.5165", "2024539.8773"], ["1.65", "2.8285", "5.0043", "9.1747", "17.5153", "35.0307", "73.9538", "166.3961", "404.105", "1077.6143", "3232.843", "11315.0616", "49031.7468", "294205.052", "3245901.6393"], ["1.7678", "3.2637", "6.2554", "12.5109", "26.273", "58.3846", "138.6634", "356.5632", "1010.2628", "3232.843", "12123.2901", "56577.8946", "367756.315", "4419642.8571"], ["1.9038", "3.8076", "7.9615", "17.5153", "40.8692", "102.173", "277.3269", "831.981", "2828.7411", "11315.0616", "56577.8946", "396158.4633", "5156250.0"], ["2.0625", "4.5", "10.35", "25.3", "66.4125", "189.75", "600.875", "2163.1542", "9193.3956", "49031.7468", "367756.315", "5156250.0"], ["2.25", "5.4", "13.8", "37.95", "113.85", "379.5", "1442.1017", "6489.4628", "36774.2654", "294205.052", "4419642.8571"], ["2.475", "6.6", "18.975", "59.6357", "208.725", "834.9005", "3965.79", "23795.2169", "202288.5165", "3245901.6393"], ["2.75", "8.25", "27.1071", "99.3928", "417.45", "2087.2513", "13219.3884", "118976.0846", "2024539.8773"], ["3.0937", "10.6071", "40.6607", "178.9071", "939.2628", "6261.7803", "59488.0423", "1071428.5714"], ["3.5357", "14.1428", "65.0571", "357.8143", "2504.7058", "25047.4383", "475961.5384"], ["4.125", "19.8", "113.85", "834.9005", "8766.4925", "175345.3772"], ["4.95", "29.7", "227.7", "2504.7058", "52600.8182"], ["6.1875", "49.5", "569.2501", "12523.5607"], ["8.25", "99.0", "2277.0031"], ["12.375", "297.0"], ["24.75"]] }`
+
+// The chance to survive k reveals with m mines is C(25-m, k) / C(25, k), a multiplier
+// times that chance is what the step returns. The seeded multipliers are truncated to 4 decimals.
+func TestMinesSeededMultipliers(t *testing.T) {
+	g := &Mines{}
+	if err := JSONParams(testMinesParams, g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.MaxReveal) != MinesTiles-1 || len(g.Multipliers) != MinesTiles-1 {
+		t.Fatalf("parameters cover %d and %d mine counts", len(g.MaxReveal), len(g.Multipliers))
+	}
+
+	low, high := decimal.RequireFromString("0.9899"), decimal.RequireFromString("0.99")
+	for mines := 1; mines < MinesTiles; mines++ {
+		row := g.Multipliers[mines-1]
+		if len(row) < int(g.MaxReveal[mines-1]) {
+			t.Fatalf("%d mines can reveal %d tiles with %d multipliers", mines, g.MaxReveal[mines-1], len(row))
+		}
+
+		survive := decimal.NewFromInt(1)
+		for revealed := 1; revealed <= int(g.MaxReveal[mines-1]); revealed++ {
+			left := int64(MinesTiles - revealed + 1)
+			survive = survive.Mul(decimal.NewFromInt(left - int64(mines))).Div(decimal.NewFromInt(left))
+			// the chance is divided to 16 decimals
+			rtp := row[revealed-1].Mul(survive).Round(8)
+			if rtp.LessThan(low) || rtp.GreaterThan(high) {
+				t.Errorf("%d mines, %d revealed returns %s", mines, revealed, rtp.StringFixed(6))
+			}
+		}
+	}
+}
+
+// A biased shuffle would put the mine on some tiles more often and change the return of every
+// multiplier. The games are played with fixed seeds, so the chi-squared statistic is the same on
+// every run, 51.18 is the 0.001 critical value for 24 degrees of freedom.
+func TestMinesLayoutIsUniform(t *testing.T) {
+	const games = 25_000
+	counts := make([]int, MinesTiles)
+	bet := requests.Bet{Amount: decimal.NewFromInt(1), Data: testData(t, MinesData{Mines: 1})}
+	for game := range uint64(games) {
+		result, err := testMines().StartPlaying(bet, testNumbers(game, MinesTiles-1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := MinesState{}
+		if err := json.Unmarshal([]byte(result.Data), &state); err != nil {
+			t.Fatal(err)
+		}
+		for tile, mine := range state.Layout {
+			if mine {
+				counts[tile]++
+			}
+		}
+	}
+
+	expected := float64(games) / MinesTiles
+	chiSquared := 0.0
+	for _, count := range counts {
+		chiSquared += (float64(count) - expected) * (float64(count) - expected) / expected
+	}
+	if chiSquared > 51.18 {
+		t.Errorf("chi-squared of the mine tiles is %.2f, counts %v", chiSquared, counts)
+	}
+}
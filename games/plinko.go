@@ -9,6 +9,10 @@ import (
 	"greekkeepers.io/backend/requests"
 )
 
+func init() {
+	RegisterStateless("Plinko", func() StatelessGameEngine { return &Plinko{} }, JSONParams)
+}
+
 type PlinkoData struct {
 	NumRows uint64 `json:"num_rows"`
 	Risk    uint64 `json:"risk"`
@@ -109,6 +113,23 @@ func (g *Plinko) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult,
 	}, nil
 }
 
+func (g *Plinko) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	data := PlinkoData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if data.NumRows < 8 || data.NumRows > 16 {
+		return decimal.Zero, errors.New("bad rows number")
+	}
+	if data.Risk >= 3 {
+		return decimal.Zero, errors.New("bad risk")
+	}
+
+	multipliers := g.Multipliers[data.Risk][data.NumRows-8]
+	return decimal.Max(multipliers[0], multipliers[1:]...), nil
+}
+
 func (*Plinko) NumbersPerBet() uint64 {
 	return 1
 }
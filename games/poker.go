@@ -10,6 +10,10 @@ import (
 	"greekkeepers.io/backend/requests"
 )
 
+func init() {
+	RegisterStateful("Poker", func() StatefulGameEngine { return &Poker{} }, JSONParams)
+}
+
 type PokerData struct{}
 
 type Card struct {
@@ -130,6 +134,17 @@ func (g *Poker) ContinuePlaying(state db.GameState, bet requests.ContinueGame, r
 	}, nil
 }
 
+// Resolve evaluates an abandoned game with the hand as it was dealt.
+func (g *Poker) Resolve(state db.GameState) (db.GameResult, error) {
+	data, _ := json.Marshal(PokerContinueData{Replace: false})
+	return g.ContinuePlaying(state, requests.ContinueGame{Data: string(data)}, nil)
+}
+
+// MaxMultiplier is the royal flush payout of determinePayout.
+func (g *Poker) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	return decimal.New(100, 0), nil
+}
+
 func (*Poker) NumbersPerBet() uint64 {
 	return 5
 }
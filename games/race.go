@@ -9,13 +9,32 @@ import (
 	"greekkeepers.io/backend/requests"
 )
 
+func init() {
+	RegisterStateless("Race", func() StatelessGameEngine { return &Race{render: raceFinishingOrder} }, JSONParams)
+	RegisterStateless("CarRace", func() StatelessGameEngine { return &Race{render: raceFinishingOrder} }, JSONParams)
+	RegisterStateless("Thimbles", func() StatelessGameEngine { return &Race{render: thimblesCups} }, JSONParams)
+}
+
+// RaceData picks one of the cars, or one of the cups in Thimbles.
 type RaceData struct {
 	Car uint64 `json:"car"`
 }
 
+// RaceReturnData has what the frontend shows for every game of the bet,
+// FinishingOrders for the races and Cups for Thimbles.
+type RaceReturnData struct {
+	Car             uint64     `json:"car"`
+	FinishingOrders [][]uint64 `json:"finishing_orders,omitempty"`
+	Cups            [][]bool   `json:"cups,omitempty"`
+}
+
+// Race is a pick one of N game, the variants registered with it only differ
+// in their parameters and in how the outcome is rendered.
 type Race struct {
 	ProfitCoef decimal.Decimal `json:"profit_coef"`
 	CarsAmount uint64          `json:"cars_amount"`
+
+	render func(data *RaceReturnData, number uint64, cars uint64)
 }
 
 func (g *Race) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult, error) {
@@ -25,6 +44,9 @@ func (g *Race) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult, er
 		return db.GameResult{}, err
 	}
 
+	if g.CarsAmount < 2 {
+		return db.GameResult{}, errors.New("cars_amount must be at least 2")
+	}
 	if data.Car >= g.CarsAmount {
 		return db.GameResult{}, errors.New("Bad car")
 	}
@@ -35,18 +57,20 @@ func (g *Race) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult, er
 
 	profit := bet.Amount.Mul(g.ProfitCoef)
 
+	returnData := RaceReturnData{Car: data.Car}
 	outcomes := make([]uint64, len(randomNumbers))
 	profits := make([]decimal.Decimal, len(randomNumbers))
 	for game, number := range randomNumbers {
 		winnerCar := number % g.CarsAmount
 		outcomes[game] = winnerCar
+		g.render(&returnData, number, g.CarsAmount)
 
 		if data.Car == winnerCar {
 			totalProfit = totalProfit.Add(profit)
-			totalValue = totalProfit.Add(profit)
+			totalValue = totalValue.Add(profit.Sub(bet.Amount))
 			profits[game] = profit
 		} else {
-			totalValue = totalValue.Sub(profit)
+			totalValue = totalValue.Sub(bet.Amount)
 			profits[game] = decimal.Zero
 		}
 
@@ -56,8 +80,10 @@ func (g *Race) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult, er
 			break
 		}
 	}
-	if games != bet.NumGames {
-		totalProfit = totalProfit.Add(decimal.NewFromUint64(bet.NumGames - games))
+
+	retData, err := json.Marshal(returnData)
+	if err != nil {
+		return db.GameResult{}, err
 	}
 
 	return db.GameResult{
@@ -65,11 +91,45 @@ func (g *Race) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult, er
 		Outcomes:    outcomes[0:games],
 		Profits:     profits[0:games],
 		NumGames:    uint32(games),
-		Data:        bet.Data,
+		Data:        string(retData),
 		Finished:    true,
 	}, nil
 }
 
+// raceFinishingOrder puts the winner first, the rest of the order is read from
+// the part of the number the winner didn't use.
+func raceFinishingOrder(data *RaceReturnData, number uint64, cars uint64) {
+	winner := number % cars
+	rest := number / cars
+
+	left := make([]uint64, 0, cars-1)
+	for car := range cars {
+		if car != winner {
+			left = append(left, car)
+		}
+	}
+
+	order := []uint64{winner}
+	for len(left) > 0 {
+		next := rest % uint64(len(left))
+		rest /= uint64(len(left))
+		order = append(order, left[next])
+		left = append(left[:next], left[next+1:]...)
+	}
+	data.FinishingOrders = append(data.FinishingOrders, order)
+}
+
+// thimblesCups marks the cup the ball is under.
+func thimblesCups(data *RaceReturnData, number uint64, cups uint64) {
+	positions := make([]bool, cups)
+	positions[number%cups] = true
+	data.Cups = append(data.Cups, positions)
+}
+
+func (g *Race) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	return g.ProfitCoef, nil
+}
+
 func (*Race) NumbersPerBet() uint64 {
 	return 1
 }
@@ -0,0 +1,136 @@
+package games
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/requests"
+)
+
+// testRaceParams are the seeded parameters of the pick-one games.
+var testRaceParams = map[string]string{
+	"Race":     `{"profit_coef":"4.9", "cars_amount":5}`,
+	"CarRace":  `{"profit_coef":"1.94", "cars_amount":2}`,
+	"Thimbles": `{"profit_coef":"2.82", "cars_amount":3}`,
+}
+
+func testRace(t *testing.T, name string) StatelessGameEngine {
+	t.Helper()
+	game, ok, err := NewStateless(name, testRaceParams[name])
+	if err != nil || !ok {
+		t.Fatalf("%s isn't registered: %v", name, err)
+	}
+	return game
+}
+
+func raceBet(t *testing.T, car uint64, numGames uint64) requests.Bet {
+	t.Helper()
+	return requests.Bet{Amount: decimal.NewFromInt(10), NumGames: numGames, Data: testData(t, RaceData{Car: car})}
+}
+
+func TestRacePlay(t *testing.T) {
+	tests := []struct {
+		game   string
+		car    uint64
+		number uint64
+		profit decimal.Decimal
+	}{
+		{game: "Race", car: 3, number: 8, profit: decimal.NewFromInt(49)},
+		{game: "Race", car: 3, number: 9, profit: decimal.Zero},
+		{game: "CarRace", car: 1, number: 7, profit: decimal.RequireFromString("19.4")},
+		{game: "CarRace", car: 1, number: 8, profit: decimal.Zero},
+		{game: "Thimbles", car: 0, number: 9, profit: decimal.RequireFromString("28.2")},
+		{game: "Thimbles", car: 2, number: 9, profit: decimal.Zero},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.game, func(t *testing.T) {
+			result, err := testRace(t, tt.game).Play(raceBet(t, tt.car, 1), []uint64{tt.number})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.TotalProfit.Equal(tt.profit) || len(result.Profits) != 1 || !result.Profits[0].Equal(tt.profit) {
+				t.Errorf("profit = %s %v, want %s", result.TotalProfit, result.Profits, tt.profit)
+			}
+		})
+	}
+}
+
+func TestRaceRender(t *testing.T) {
+	t.Run("finishing order starts with the winner", func(t *testing.T) {
+		data := RaceReturnData{}
+		for number := range uint64(200) {
+			raceFinishingOrder(&data, number, 5)
+		}
+		for number, order := range data.FinishingOrders {
+			if order[0] != uint64(number)%5 {
+				t.Fatalf("order %v of %d doesn't start with the winner", order, number)
+			}
+			sorted := slices.Clone(order)
+			slices.Sort(sorted)
+			if !slices.Equal(sorted, []uint64{0, 1, 2, 3, 4}) {
+				t.Fatalf("order %v of %d isn't every car once", order, number)
+			}
+		}
+	})
+	t.Run("thimbles marks the cup with the ball", func(t *testing.T) {
+		data := RaceReturnData{}
+		thimblesCups(&data, 7, 3)
+		if !slices.Equal(data.Cups[0], []bool{false, true, false}) {
+			t.Errorf("cups = %v", data.Cups[0])
+		}
+	})
+}
+
+// Games skipped by stop win or stop loss aren't played, the engine only debits the games
+// that were played so the game must not pay their stake back.
+func TestRaceStops(t *testing.T) {
+	// car 0 wins on 30 and loses on 1 in every variant
+	winFirst := []uint64{30, 1, 30, 1}
+	lossFirst := []uint64{1, 1, 30, 30}
+	tests := []struct {
+		game     string
+		numbers  []uint64
+		stopWin  decimal.Decimal
+		stopLoss decimal.Decimal
+		games    uint32
+		profit   decimal.Decimal
+	}{
+		{game: "Race", numbers: winFirst, games: 4, profit: decimal.NewFromInt(98)},
+		{game: "Race", numbers: winFirst, stopWin: decimal.NewFromInt(20), games: 1, profit: decimal.NewFromInt(49)},
+		{game: "Race", numbers: lossFirst, stopLoss: decimal.NewFromInt(-20), games: 2, profit: decimal.Zero},
+		{game: "CarRace", numbers: winFirst, stopWin: decimal.NewFromInt(5), games: 1, profit: decimal.RequireFromString("19.4")},
+		{game: "CarRace", numbers: lossFirst, stopLoss: decimal.NewFromInt(-20), games: 2, profit: decimal.Zero},
+		{game: "Thimbles", numbers: winFirst, stopWin: decimal.NewFromInt(10), games: 1, profit: decimal.RequireFromString("28.2")},
+		{game: "Thimbles", numbers: lossFirst, stopLoss: decimal.NewFromInt(-20), games: 2, profit: decimal.Zero},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.game, func(t *testing.T) {
+			bet := raceBet(t, 0, 4)
+			bet.StopWin = tt.stopWin
+			bet.StopLoss = tt.stopLoss
+			result, err := testRace(t, tt.game).Play(bet, tt.numbers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.NumGames != tt.games || len(result.Outcomes) != int(tt.games) || len(result.Profits) != int(tt.games) {
+				t.Errorf("played %d games with %d outcomes and %d profits, want %d", result.NumGames, len(result.Outcomes), len(result.Profits), tt.games)
+			}
+			if !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("profit = %s, want %s", result.TotalProfit, tt.profit)
+			}
+		})
+	}
+}
+
+func TestRaceBadBets(t *testing.T) {
+	if _, err := testRace(t, "Thimbles").Play(raceBet(t, 3, 1), []uint64{0}); err == nil {
+		t.Error("bet on a cup that doesn't exist was accepted")
+	}
+	g := &Race{ProfitCoef: decimal.NewFromInt(2), CarsAmount: 1, render: raceFinishingOrder}
+	if _, err := g.Play(raceBet(t, 0, 1), []uint64{0}); err == nil {
+		t.Error("race with a single car was played")
+	}
+}
@@ -0,0 +1,122 @@
+package games
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ParamsDecoder fills a freshly built game with the parameters stored in db.Game.Parameters.
+type ParamsDecoder func(params string, game interface{}) error
+
+// JSONParams is the decoder used by every game whose parameters are plain json.
+func JSONParams(params string, game interface{}) error {
+	return json.Unmarshal([]byte(params), game)
+}
+
+type statelessEntry struct {
+	factory func() StatelessGameEngine
+	decode  ParamsDecoder
+}
+
+type statefulEntry struct {
+	factory func() StatefulGameEngine
+	decode  ParamsDecoder
+}
+
+var (
+	registryMu sync.RWMutex
+	stateless  = make(map[string]statelessEntry)
+	stateful   = make(map[string]statefulEntry)
+)
+
+// RegisterStateless makes a stateless game available under the name used in the games table.
+// It is meant to be called from the init function of the file implementing the game.
+func RegisterStateless(name string, factory func() StatelessGameEngine, decode ParamsDecoder) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := stateless[name]; ok {
+		panic(fmt.Sprintf("game %s registered twice", name))
+	}
+	if _, ok := stateful[name]; ok {
+		panic(fmt.Sprintf("game %s registered twice", name))
+	}
+	stateless[name] = statelessEntry{factory: factory, decode: decode}
+}
+
+// RegisterStateful makes a stateful game available under the name used in the games table.
+// It is meant to be called from the init function of the file implementing the game.
+func RegisterStateful(name string, factory func() StatefulGameEngine, decode ParamsDecoder) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := stateless[name]; ok {
+		panic(fmt.Sprintf("game %s registered twice", name))
+	}
+	if _, ok := stateful[name]; ok {
+		panic(fmt.Sprintf("game %s registered twice", name))
+	}
+	stateful[name] = statefulEntry{factory: factory, decode: decode}
+}
+
+// NewStateless builds the stateless game registered under name.
+// ok is false when no stateless game has that name.
+func NewStateless(name string, params string) (game StatelessGameEngine, ok bool, err error) {
+	registryMu.RLock()
+	entry, ok := stateless[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	game = entry.factory()
+	if err := entry.decode(params, game); err != nil {
+		return nil, true, fmt.Errorf("parsing %s parameters: %w", name, err)
+	}
+	return game, true, nil
+}
+
+// NewStateful builds the stateful game registered under name.
+// ok is false when no stateful game has that name.
+func NewStateful(name string, params string) (game StatefulGameEngine, ok bool, err error) {
+	registryMu.RLock()
+	entry, ok := stateful[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	game = entry.factory()
+	if err := entry.decode(params, game); err != nil {
+		return nil, true, fmt.Errorf("parsing %s parameters: %w", name, err)
+	}
+	return game, true, nil
+}
+
+// IsRegistered reports whether any engine is registered under name.
+func IsRegistered(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	_, isStateless := stateless[name]
+	_, isStateful := stateful[name]
+	return isStateless || isStateful
+}
+
+// Registered returns the names of all registered games in alphabetical order.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(stateless)+len(stateful))
+	for name := range stateless {
+		names = append(names, name)
+	}
+	for name := range stateful {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -16,6 +16,8 @@ func init() {
 	U64_UPPER_BOUNDARY, _ = decimal.NewFromString("18446744073709551615")
 	HUNDRED, _ = decimal.NewFromString("100")
 	NINTYNINE, _ = decimal.NewFromString("99")
+
+	RegisterStateless("Rocket", func() StatelessGameEngine { return &Rocket{} }, JSONParams)
 }
 
 type RocketData struct {
@@ -87,6 +89,15 @@ func (g *Rocket) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult,
 	}, nil
 }
 
+func (g *Rocket) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	data := RocketData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return data.Multiplier, nil
+}
+
 func (*Rocket) NumbersPerBet() uint64 {
 	return 1
 }
@@ -0,0 +1,273 @@
+package games
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+)
+
+// RouletteNumbers is the amount of pockets of the European wheel, 0 to 36.
+const RouletteNumbers = 37
+
+var rouletteRed = []uint8{1, 3, 5, 7, 9, 12, 14, 16, 18, 19, 21, 23, 25, 27, 30, 32, 34, 36}
+
+func init() {
+	RegisterStateless("Roulette", func() StatelessGameEngine { return &Roulette{} }, decodeRoulette)
+}
+
+// RoulettePlacement is a single chip on the table. Numbers are used by straight, split
+// and corner bets, Index picks the dozen or the column, starting at 0.
+type RoulettePlacement struct {
+	Kind    string          `json:"kind"`
+	Numbers []uint8         `json:"numbers"`
+	Index   uint8           `json:"index"`
+	Amount  decimal.Decimal `json:"amount"`
+}
+
+// RouletteData are the placements of every spin, their amounts must add up to the bet amount.
+type RouletteData struct {
+	Placements []RoulettePlacement `json:"placements"`
+}
+
+type RouletteSpin struct {
+	Number uint8 `json:"number"`
+	// Payouts has the payout of every placement, in the order they were placed
+	Payouts []decimal.Decimal `json:"payouts"`
+}
+
+type RouletteReturnData struct {
+	Placements []RoulettePlacement `json:"placements"`
+	Spins      []RouletteSpin      `json:"spins"`
+}
+
+// Roulette coefficients are the whole payout of a winning chip, the stake included.
+// ZeroCoef pays a straight bet on zero, NumCoef a straight bet on any other number.
+type Roulette struct {
+	ZeroCoef  decimal.Decimal `json:"zero_coef"`
+	NumCoef   decimal.Decimal `json:"num_coef"`
+	Num2Coef  decimal.Decimal `json:"num2_coef"`
+	Num4Coef  decimal.Decimal `json:"num4_coef"`
+	Num12Coef decimal.Decimal `json:"num12_coef"`
+	Num18Coef decimal.Decimal `json:"num18_coef"`
+}
+
+// decodeRoulette rejects coefficients that pay back more than the wheel takes in,
+// a chip can't win more than 37 times its amount over the 37 pockets.
+func decodeRoulette(params string, game interface{}) error {
+	g := game.(*Roulette)
+	if err := json.Unmarshal([]byte(params), g); err != nil {
+		return err
+	}
+
+	coefs := []struct {
+		name    string
+		coef    decimal.Decimal
+		pockets int64
+	}{
+		{"zero_coef", g.ZeroCoef, 1},
+		{"num_coef", g.NumCoef, 1},
+		{"num2_coef", g.Num2Coef, 2},
+		{"num4_coef", g.Num4Coef, 4},
+		{"num12_coef", g.Num12Coef, 12},
+		{"num18_coef", g.Num18Coef, 18},
+	}
+	for _, c := range coefs {
+		if !c.coef.IsPositive() {
+			return fmt.Errorf("%s must be positive", c.name)
+		}
+		if c.coef.Mul(decimal.NewFromInt(c.pockets)).GreaterThan(decimal.NewFromInt(RouletteNumbers)) {
+			return fmt.Errorf("%s pays more than the wheel takes in", c.name)
+		}
+	}
+	return nil
+}
+
+func (g *Roulette) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult, error) {
+	data, err := g.parseData(bet)
+	if err != nil {
+		return db.GameResult{}, err
+	}
+
+	totalProfit := decimal.Zero
+	totalValue := decimal.Zero
+	games := uint64(0)
+
+	outcomes := make([]uint64, len(randomNumbers))
+	profits := make([]decimal.Decimal, len(randomNumbers))
+	spins := make([]RouletteSpin, len(randomNumbers))
+	for game, number := range randomNumbers {
+		pocket := uint8(number % RouletteNumbers)
+		payouts, payout := g.payouts(data.Placements, pocket)
+
+		spins[game] = RouletteSpin{Number: pocket, Payouts: payouts}
+		outcomes[game] = uint64(pocket)
+		profits[game] = payout
+		games++
+
+		totalProfit = totalProfit.Add(payout)
+		totalValue = totalValue.Add(payout.Sub(bet.Amount))
+
+		if (!bet.StopWin.IsZero() && totalValue.GreaterThanOrEqual(bet.StopWin)) || (!bet.StopLoss.IsZero() && totalValue.LessThanOrEqual(bet.StopLoss)) {
+			break
+		}
+	}
+
+	retData, err := json.Marshal(RouletteReturnData{
+		Placements: data.Placements,
+		Spins:      spins[:games],
+	})
+	if err != nil {
+		return db.GameResult{}, err
+	}
+
+	return db.GameResult{
+		TotalProfit: totalProfit,
+		Outcomes:    outcomes[:games],
+		Profits:     profits[:games],
+		NumGames:    uint32(games),
+		Data:        string(retData),
+		Finished:    true,
+	}, nil
+}
+
+// MaxMultiplier is the payout of the pocket that pays the most for the placements.
+func (g *Roulette) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	data, err := g.parseData(bet)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	best := decimal.Zero
+	for pocket := range uint8(RouletteNumbers) {
+		_, payout := g.payouts(data.Placements, pocket)
+		best = decimal.Max(best, payout)
+	}
+	return best.Div(bet.Amount), nil
+}
+
+func (*Roulette) NumbersPerBet() uint64 {
+	return 1
+}
+
+func (g *Roulette) parseData(bet requests.Bet) (RouletteData, error) {
+	data := RouletteData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return RouletteData{}, err
+	}
+	if len(data.Placements) == 0 {
+		return RouletteData{}, errors.New("No placements")
+	}
+
+	total := decimal.Zero
+	for i, placement := range data.Placements {
+		if !placement.Amount.IsPositive() {
+			return RouletteData{}, fmt.Errorf("Placement %d has no amount", i)
+		}
+		if err := checkPlacement(placement); err != nil {
+			return RouletteData{}, fmt.Errorf("Placement %d: %w", i, err)
+		}
+		total = total.Add(placement.Amount)
+	}
+	if !total.Equal(bet.Amount) {
+		return RouletteData{}, errors.New("Placements don't add up to the bet amount")
+	}
+	return data, nil
+}
+
+func checkPlacement(placement RoulettePlacement) error {
+	numbers := slices.Clone(placement.Numbers)
+	slices.Sort(numbers)
+
+	switch placement.Kind {
+	case "straight":
+		if len(numbers) != 1 || numbers[0] >= RouletteNumbers {
+			return errors.New("Straight bet takes a single number")
+		}
+	case "split":
+		if len(numbers) != 2 || numbers[1] >= RouletteNumbers {
+			return errors.New("Split bet takes two numbers")
+		}
+		low, high := numbers[0], numbers[1]
+		zeroSplit := low == 0 && high <= 3
+		sideSplit := low != 0 && high == low+1 && low%3 != 0
+		rowSplit := low != 0 && high == low+3
+		if !zeroSplit && !sideSplit && !rowSplit {
+			return errors.New("Split numbers aren't next to each other")
+		}
+	case "corner":
+		if len(numbers) != 4 {
+			return errors.New("Corner bet takes four numbers")
+		}
+		first := numbers[0]
+		if first == 0 || first%3 == 0 || !slices.Equal(numbers, []uint8{first, first + 1, first + 3, first + 4}) || first+4 > 36 {
+			return errors.New("Corner numbers don't make a square")
+		}
+	case "dozen", "column":
+		if placement.Index > 2 {
+			return errors.New("Index must be 0, 1 or 2")
+		}
+	case "red", "black", "odd", "even", "low", "high":
+	default:
+		return fmt.Errorf("Unknown bet kind %q", placement.Kind)
+	}
+	return nil
+}
+
+// payouts returns the payout of every placement and their sum when the ball lands on pocket.
+func (g *Roulette) payouts(placements []RoulettePlacement, pocket uint8) ([]decimal.Decimal, decimal.Decimal) {
+	total := decimal.Zero
+	payouts := make([]decimal.Decimal, len(placements))
+	for i, placement := range placements {
+		if coef, won := g.wins(placement, pocket); won {
+			payouts[i] = placement.Amount.Mul(coef)
+			total = total.Add(payouts[i])
+		} else {
+			payouts[i] = decimal.Zero
+		}
+	}
+	return payouts, total
+}
+
+func (g *Roulette) wins(placement RoulettePlacement, pocket uint8) (decimal.Decimal, bool) {
+	switch placement.Kind {
+	case "straight":
+		if pocket == 0 {
+			return g.ZeroCoef, placement.Numbers[0] == 0
+		}
+		return g.NumCoef, placement.Numbers[0] == pocket
+	case "split":
+		return g.Num2Coef, slices.Contains(placement.Numbers, pocket)
+	case "corner":
+		return g.Num4Coef, slices.Contains(placement.Numbers, pocket)
+	}
+
+	// outside bets lose on zero
+	if pocket == 0 {
+		return decimal.Zero, false
+	}
+	switch placement.Kind {
+	case "dozen":
+		return g.Num12Coef, (pocket-1)/12 == placement.Index
+	case "column":
+		return g.Num12Coef, (pocket-1)%3 == placement.Index
+	case "red":
+		return g.Num18Coef, slices.Contains(rouletteRed, pocket)
+	case "black":
+		return g.Num18Coef, !slices.Contains(rouletteRed, pocket)
+	case "odd":
+		return g.Num18Coef, pocket%2 == 1
+	case "even":
+		return g.Num18Coef, pocket%2 == 0
+	case "low":
+		return g.Num18Coef, pocket <= 18
+	case "high":
+		return g.Num18Coef, pocket > 18
+	}
+	return decimal.Zero, false
+}
@@ -0,0 +1,262 @@
+package games
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/requests"
+)
+
+const testRouletteParams = `{ "zero_coef":"34.8148", "num_coef":"34.8148", "num2_coef":"17.3752", "num4_coef":"8.6957", "num12_coef":"2.8986", "num18_coef":"1.9322" }`
+
+func testRoulette(t *testing.T) *Roulette {
+	t.Helper()
+	g := &Roulette{}
+	if err := decodeRoulette(testRouletteParams, g); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func rouletteBet(t *testing.T, placements ...RoulettePlacement) requests.Bet {
+	t.Helper()
+	amount := decimal.Zero
+	for _, placement := range placements {
+		amount = amount.Add(placement.Amount)
+	}
+	return requests.Bet{Amount: amount, NumGames: 1, Data: testData(t, RouletteData{Placements: placements})}
+}
+
+func roulettePlacement(kind string, numbers ...uint8) RoulettePlacement {
+	return RoulettePlacement{Kind: kind, Numbers: numbers, Amount: decimal.NewFromInt(1)}
+}
+
+func TestRoulettePlay(t *testing.T) {
+	tests := []struct {
+		name      string
+		placement RoulettePlacement
+		pocket    uint64
+		profit    decimal.Decimal
+	}{
+		{name: "straight", placement: roulettePlacement("straight", 17), pocket: 17, profit: decimal.RequireFromString("34.8148")},
+		{name: "straight on zero", placement: roulettePlacement("straight", 0), pocket: 0, profit: decimal.RequireFromString("34.8148")},
+		{name: "straight misses zero", placement: roulettePlacement("straight", 17), pocket: 0, profit: decimal.Zero},
+		{name: "straight misses", placement: roulettePlacement("straight", 17), pocket: 18, profit: decimal.Zero},
+		{name: "split", placement: roulettePlacement("split", 17, 20), pocket: 20, profit: decimal.RequireFromString("17.3752")},
+		{name: "split with zero", placement: roulettePlacement("split", 0, 2), pocket: 0, profit: decimal.RequireFromString("17.3752")},
+		{name: "corner", placement: roulettePlacement("corner", 17, 18, 20, 21), pocket: 21, profit: decimal.RequireFromString("8.6957")},
+		{name: "dozen", placement: RoulettePlacement{Kind: "dozen", Index: 1, Amount: decimal.NewFromInt(1)}, pocket: 24, profit: decimal.RequireFromString("2.8986")},
+		{name: "column", placement: RoulettePlacement{Kind: "column", Index: 2, Amount: decimal.NewFromInt(1)}, pocket: 36, profit: decimal.RequireFromString("2.8986")},
+		{name: "red", placement: roulettePlacement("red"), pocket: 1, profit: decimal.RequireFromString("1.9322")},
+		{name: "black", placement: roulettePlacement("black"), pocket: 1, profit: decimal.Zero},
+		{name: "even", placement: roulettePlacement("even"), pocket: 36, profit: decimal.RequireFromString("1.9322")},
+		{name: "high", placement: roulettePlacement("high"), pocket: 18, profit: decimal.Zero},
+		{name: "dozen loses on zero", placement: RoulettePlacement{Kind: "dozen", Amount: decimal.NewFromInt(1)}, pocket: 0, profit: decimal.Zero},
+		{name: "even loses on zero", placement: roulettePlacement("even"), pocket: 0, profit: decimal.Zero},
+		{name: "low loses on zero", placement: roulettePlacement("low"), pocket: 0, profit: decimal.Zero},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := testRoulette(t).Play(rouletteBet(t, tt.placement), []uint64{tt.pocket + RouletteNumbers})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("profit = %s, want %s", result.TotalProfit, tt.profit)
+			}
+			if len(result.Outcomes) != 1 || result.Outcomes[0] != tt.pocket {
+				t.Errorf("outcomes = %v, want [%d]", result.Outcomes, tt.pocket)
+			}
+		})
+	}
+}
+
+func TestRouletteZeroCoef(t *testing.T) {
+	g := testRoulette(t)
+	g.ZeroCoef = decimal.NewFromInt(30)
+
+	tests := []struct {
+		name   string
+		number uint8
+		pocket uint64
+		profit decimal.Decimal
+	}{
+		{name: "zero pays zero_coef", number: 0, pocket: 0, profit: decimal.NewFromInt(30)},
+		{name: "other numbers pay num_coef", number: 5, pocket: 5, profit: decimal.RequireFromString("34.8148")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := g.Play(rouletteBet(t, roulettePlacement("straight", tt.number)), []uint64{tt.pocket})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("profit = %s, want %s", result.TotalProfit, tt.profit)
+			}
+		})
+	}
+}
+
+func TestDecodeRoulette(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+		fails  bool
+	}{
+		{name: "seeded", params: testRouletteParams},
+		{name: "even money", params: `{ "zero_coef":"37", "num_coef":"37", "num2_coef":"18.5", "num4_coef":"9.25", "num12_coef":"3", "num18_coef":"2" }`},
+		{name: "zero pays more than the wheel", params: `{ "zero_coef":"52", "num_coef":"34.8148", "num2_coef":"17.3752", "num4_coef":"8.6957", "num12_coef":"2.8986", "num18_coef":"1.9322" }`, fails: true},
+		{name: "red pays more than the wheel", params: `{ "zero_coef":"34.8148", "num_coef":"34.8148", "num2_coef":"17.3752", "num4_coef":"8.6957", "num12_coef":"2.8986", "num18_coef":"2.1" }`, fails: true},
+		{name: "missing zero_coef", params: `{ "num_coef":"34.8148", "num2_coef":"17.3752", "num4_coef":"8.6957", "num12_coef":"2.8986", "num18_coef":"1.9322" }`, fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decodeRoulette(tt.params, &Roulette{})
+			if tt.fails && err == nil {
+				t.Error("params were accepted")
+			}
+			if !tt.fails && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRoulettePlacements(t *testing.T) {
+	tests := []struct {
+		name  string
+		bet   func(t *testing.T) requests.Bet
+		fails bool
+	}{
+		{
+			name: "several placements",
+			bet: func(t *testing.T) requests.Bet {
+				return rouletteBet(t, roulettePlacement("straight", 36), roulettePlacement("split", 3, 6), roulettePlacement("red"))
+			},
+		},
+		{
+			name:  "no placements",
+			bet:   func(t *testing.T) requests.Bet { return rouletteBet(t) },
+			fails: true,
+		},
+		{
+			name: "amounts don't add up to the bet",
+			bet: func(t *testing.T) requests.Bet {
+				bet := rouletteBet(t, roulettePlacement("red"))
+				bet.Amount = decimal.NewFromInt(2)
+				return bet
+			},
+			fails: true,
+		},
+		{
+			name: "placement without an amount",
+			bet: func(t *testing.T) requests.Bet {
+				return rouletteBet(t, roulettePlacement("red"), RoulettePlacement{Kind: "black", Amount: decimal.Zero})
+			},
+			fails: true,
+		},
+		{
+			name:  "straight past 36",
+			bet:   func(t *testing.T) requests.Bet { return rouletteBet(t, roulettePlacement("straight", 37)) },
+			fails: true,
+		},
+		{
+			name:  "split across rows",
+			bet:   func(t *testing.T) requests.Bet { return rouletteBet(t, roulettePlacement("split", 3, 4)) },
+			fails: true,
+		},
+		{
+			name:  "corner that isn't a square",
+			bet:   func(t *testing.T) requests.Bet { return rouletteBet(t, roulettePlacement("corner", 3, 4, 6, 7)) },
+			fails: true,
+		},
+		{
+			name: "dozen past the third",
+			bet: func(t *testing.T) requests.Bet {
+				return rouletteBet(t, RoulettePlacement{Kind: "dozen", Index: 3, Amount: decimal.NewFromInt(1)})
+			},
+			fails: true,
+		},
+		{
+			name:  "unknown kind",
+			bet:   func(t *testing.T) requests.Bet { return rouletteBet(t, roulettePlacement("green")) },
+			fails: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := testRoulette(t).Play(tt.bet(t), []uint64{0})
+			if tt.fails && err == nil {
+				t.Error("bet was accepted")
+			}
+			if !tt.fails && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRouletteMaxMultiplier(t *testing.T) {
+	// 17 wins the straight, the split and the red, a pocket can't win both red and black
+	bet := rouletteBet(t,
+		roulettePlacement("straight", 17),
+		roulettePlacement("split", 17, 20),
+		roulettePlacement("black"),
+		roulettePlacement("red"),
+	)
+	got, err := testRoulette(t).MaxMultiplier(bet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := decimal.RequireFromString("34.8148").Add(decimal.RequireFromString("17.3752")).Add(decimal.RequireFromString("1.9322")).Div(decimal.NewFromInt(4))
+	if !got.Equal(want) {
+		t.Errorf("max multiplier = %s, want %s", got, want)
+	}
+}
+
+// A chip on every pocket of the wheel pays the coefficient of its kind once for every pocket
+// it wins on, that total over the 37 pockets is the return of the placement.
+func TestRouletteSeededReturn(t *testing.T) {
+	tests := []struct {
+		name      string
+		placement RoulettePlacement
+		paid      string
+	}{
+		{name: "straight", placement: roulettePlacement("straight", 0), paid: "34.8148"},
+		{name: "split", placement: roulettePlacement("split", 1, 2), paid: "34.7504"},
+		{name: "corner", placement: roulettePlacement("corner", 1, 2, 4, 5), paid: "34.7828"},
+		{name: "dozen", placement: RoulettePlacement{Kind: "dozen", Amount: decimal.NewFromInt(1)}, paid: "34.7832"},
+		{name: "column", placement: RoulettePlacement{Kind: "column", Amount: decimal.NewFromInt(1)}, paid: "34.7832"},
+		{name: "red", placement: roulettePlacement("red"), paid: "34.7796"},
+		{name: "black", placement: roulettePlacement("black"), paid: "34.7796"},
+		{name: "odd", placement: roulettePlacement("odd"), paid: "34.7796"},
+		{name: "even", placement: roulettePlacement("even"), paid: "34.7796"},
+		{name: "low", placement: roulettePlacement("low"), paid: "34.7796"},
+		{name: "high", placement: roulettePlacement("high"), paid: "34.7796"},
+	}
+
+	g := testRoulette(t)
+	pockets := make([]uint64, RouletteNumbers)
+	for pocket := range pockets {
+		pockets[pocket] = uint64(pocket)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bet := rouletteBet(t, tt.placement)
+			bet.NumGames = RouletteNumbers
+			result, err := g.Play(bet, pockets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := decimal.RequireFromString(tt.paid); !result.TotalProfit.Equal(want) {
+				t.Errorf("paid %s over the wheel, want %s", result.TotalProfit, want)
+			}
+			// the house keeps about 6% of every placement
+			if rtp := result.TotalProfit.Div(decimal.NewFromInt(RouletteNumbers)); rtp.GreaterThanOrEqual(decimal.RequireFromString("0.95")) {
+				t.Errorf("return to player is %s", rtp.StringFixed(4))
+			}
+		})
+	}
+}
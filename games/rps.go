@@ -9,6 +9,10 @@ import (
 	"greekkeepers.io/backend/requests"
 )
 
+func init() {
+	RegisterStateless("RPS", func() StatelessGameEngine { return &RPS{} }, JSONParams)
+}
+
 type RPSData struct {
 	Action uint64 `json:"action"` // 0 - rock, 1 - paper, 2 - scissors
 }
@@ -108,6 +112,10 @@ func (g *RPS) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult, err
 	}, nil
 }
 
+func (g *RPS) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	return decimal.Max(g.ProfitCoef, g.DrawCoef), nil
+}
+
 func (*RPS) NumbersPerBet() uint64 {
 	return 1
 }
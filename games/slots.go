@@ -0,0 +1,91 @@
+package games
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/db"
+	"greekkeepers.io/backend/requests"
+)
+
+const (
+	SlotsReels   = 3
+	SlotsSymbols = 7
+)
+
+func init() {
+	RegisterStateless("Slots", func() StatelessGameEngine { return &Slots{} }, JSONParams)
+}
+
+// SlotsReturnData has the stop of every reel for every spin, in the order of the outcomes.
+type SlotsReturnData struct {
+	Reels [][SlotsReels]uint8 `json:"reels"`
+}
+
+// Slots pays Multipliers[outcome], the outcome of a spin is the reel stops read as a base 7 number.
+type Slots struct {
+	NumOutcomes uint64            `json:"num_outcomes"`
+	Multipliers []decimal.Decimal `json:"multipliers"`
+}
+
+func (g *Slots) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult, error) {
+	if g.NumOutcomes != SlotsSymbols*SlotsSymbols*SlotsSymbols || uint64(len(g.Multipliers)) != g.NumOutcomes {
+		return db.GameResult{}, errors.New("Slots need a multiplier for every one of the 343 outcomes")
+	}
+
+	totalProfit := decimal.Zero
+	totalValue := decimal.Zero
+	games := uint64(0)
+
+	spins := len(randomNumbers) / SlotsReels
+	outcomes := make([]uint64, spins)
+	profits := make([]decimal.Decimal, spins)
+	reels := make([][SlotsReels]uint8, spins)
+	for game := range spins {
+		outcome := uint64(0)
+		for reel := range SlotsReels {
+			stop := randomNumbers[game*SlotsReels+reel] % SlotsSymbols
+			reels[game][reel] = uint8(stop)
+			outcome = outcome*SlotsSymbols + stop
+		}
+
+		payout := bet.Amount.Mul(g.Multipliers[outcome])
+		outcomes[game] = outcome
+		profits[game] = payout
+		games++
+
+		totalProfit = totalProfit.Add(payout)
+		totalValue = totalValue.Add(payout.Sub(bet.Amount))
+
+		if (!bet.StopWin.IsZero() && totalValue.GreaterThanOrEqual(bet.StopWin)) || (!bet.StopLoss.IsZero() && totalValue.LessThanOrEqual(bet.StopLoss)) {
+			break
+		}
+	}
+
+	retData, err := json.Marshal(SlotsReturnData{Reels: reels[:games]})
+	if err != nil {
+		return db.GameResult{}, err
+	}
+
+	return db.GameResult{
+		TotalProfit: totalProfit,
+		Outcomes:    outcomes[:games],
+		Profits:     profits[:games],
+		NumGames:    uint32(games),
+		Data:        string(retData),
+		Finished:    true,
+	}, nil
+}
+
+func (g *Slots) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	if len(g.Multipliers) == 0 {
+		return decimal.Zero, errors.New("Slots have no multipliers")
+	}
+	return decimal.Max(g.Multipliers[0], g.Multipliers[1:]...), nil
+}
+
+// NumbersPerBet is one number per reel.
+func (*Slots) NumbersPerBet() uint64 {
+	return SlotsReels
+}
@@ -0,0 +1,121 @@
+package games
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"greekkeepers.io/backend/requests"
+)
+
+// testSlots pays 5 on three blanks, 3 on two blanks and a cherry and 100 on three sevens.
+func testSlots() *Slots {
+	g := &Slots{NumOutcomes: SlotsSymbols * SlotsSymbols * SlotsSymbols}
+	g.Multipliers = make([]decimal.Decimal, g.NumOutcomes)
+	for outcome := range g.Multipliers {
+		g.Multipliers[outcome] = decimal.Zero
+	}
+	g.Multipliers[0] = decimal.NewFromInt(5)
+	g.Multipliers[1] = decimal.NewFromInt(3)
+	g.Multipliers[342] = decimal.NewFromInt(100)
+	return g
+}
+
+// slotsNumbers are the random numbers that stop the reels of every spin at stops.
+func slotsNumbers(stops ...[SlotsReels]uint64) []uint64 {
+	numbers := []uint64{}
+	for _, spin := range stops {
+		for _, stop := range spin {
+			numbers = append(numbers, stop+SlotsSymbols*3)
+		}
+	}
+	return numbers
+}
+
+func TestSlotsPlay(t *testing.T) {
+	tests := []struct {
+		name    string
+		stops   [SlotsReels]uint64
+		outcome uint64
+		profit  decimal.Decimal
+	}{
+		{name: "three blanks", stops: [SlotsReels]uint64{0, 0, 0}, outcome: 0, profit: decimal.NewFromInt(10)},
+		{name: "last reel is the lowest digit", stops: [SlotsReels]uint64{0, 0, 1}, outcome: 1, profit: decimal.NewFromInt(6)},
+		{name: "three sevens", stops: [SlotsReels]uint64{6, 6, 6}, outcome: 342, profit: decimal.NewFromInt(200)},
+		{name: "no win", stops: [SlotsReels]uint64{1, 2, 3}, outcome: 66, profit: decimal.Zero},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bet := requests.Bet{Amount: decimal.NewFromInt(2), NumGames: 1}
+			result, err := testSlots().Play(bet, slotsNumbers(tt.stops))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("profit = %s, want %s", result.TotalProfit, tt.profit)
+			}
+			if len(result.Outcomes) != 1 || result.Outcomes[0] != tt.outcome {
+				t.Errorf("outcomes = %v, want [%d]", result.Outcomes, tt.outcome)
+			}
+			want := testData(t, SlotsReturnData{Reels: [][SlotsReels]uint8{{uint8(tt.stops[0]), uint8(tt.stops[1]), uint8(tt.stops[2])}}})
+			if result.Data != want {
+				t.Errorf("data = %s, want %s", result.Data, want)
+			}
+		})
+	}
+}
+
+// Spins skipped by stop win or stop loss aren't played, the engine only debits the spins
+// that were played so the game must not pay their stake back.
+func TestSlotsStops(t *testing.T) {
+	win := [SlotsReels]uint64{0, 0, 0}
+	loss := [SlotsReels]uint64{1, 2, 3}
+	tests := []struct {
+		name   string
+		bet    requests.Bet
+		games  uint32
+		profit decimal.Decimal
+	}{
+		{
+			name:   "no stops play every spin",
+			bet:    requests.Bet{Amount: decimal.NewFromInt(1), NumGames: 4},
+			games:  4,
+			profit: decimal.NewFromInt(10),
+		},
+		{
+			name:   "stop win",
+			bet:    requests.Bet{Amount: decimal.NewFromInt(1), NumGames: 4, StopWin: decimal.NewFromInt(3)},
+			games:  2,
+			profit: decimal.NewFromInt(5),
+		},
+		{
+			name:   "stop loss",
+			bet:    requests.Bet{Amount: decimal.NewFromInt(1), NumGames: 4, StopLoss: decimal.NewFromInt(-1)},
+			games:  1,
+			profit: decimal.Zero,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := testSlots().Play(tt.bet, slotsNumbers(loss, win, loss, win))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.NumGames != tt.games || len(result.Outcomes) != int(tt.games) || len(result.Profits) != int(tt.games) {
+				t.Errorf("played %d games with %d outcomes and %d profits, want %d", result.NumGames, len(result.Outcomes), len(result.Profits), tt.games)
+			}
+			if !result.TotalProfit.Equal(tt.profit) {
+				t.Errorf("profit = %s, want %s", result.TotalProfit, tt.profit)
+			}
+		})
+	}
+}
+
+func TestSlotsNeedEveryMultiplier(t *testing.T) {
+	g := testSlots()
+	g.Multipliers = g.Multipliers[:SlotsSymbols]
+	if _, err := g.Play(requests.Bet{Amount: decimal.NewFromInt(1), NumGames: 1}, slotsNumbers([SlotsReels]uint64{0, 0, 0})); err == nil {
+		t.Error("slots played without a multiplier for every outcome")
+	}
+}
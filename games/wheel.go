@@ -9,6 +9,10 @@ import (
 	"greekkeepers.io/backend/requests"
 )
 
+func init() {
+	RegisterStateless("Wheel", func() StatelessGameEngine { return &Wheel{} }, JSONParams)
+}
+
 type WheelData struct {
 	Risk       uint32 `json:"risk"`
 	NumSectors uint32 `json:"num_sectors"`
@@ -77,6 +81,20 @@ func (g *Wheel) Play(bet requests.Bet, randomNumbers []uint64) (db.GameResult, e
 	}, nil
 }
 
+func (g *Wheel) MaxMultiplier(bet requests.Bet) (decimal.Decimal, error) {
+	data := WheelData{}
+	err := json.Unmarshal([]byte(bet.Data), &data)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if data.Risk > g.MaxRisk || data.NumSectors > g.MaxNumSectors {
+		return decimal.Zero, errors.New("Bad input")
+	}
+
+	multipliers := g.Multipliers[data.Risk][data.NumSectors]
+	return decimal.Max(multipliers[0], multipliers[1:]...), nil
+}
+
 func (*Wheel) NumbersPerBet() uint64 {
 	return 1
 }
@@ -11,6 +11,25 @@ type Login struct {
 	Password string `json:"password"`
 }
 
+type BetLimit struct {
+	GameID    uint            `json:"game_id"`
+	CoinID    uint            `json:"coin_id"`
+	MinStake  decimal.Decimal `json:"min_stake"`
+	MaxStake  decimal.Decimal `json:"max_stake"`
+	MaxGames  uint64          `json:"max_games"`
+	MaxPayout decimal.Decimal `json:"max_payout"`
+}
+
+type BankrollTopUp struct {
+	CoinID uint            `json:"coin_id"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+type BankrollExposure struct {
+	CoinID           uint            `json:"coin_id"`
+	ExposureFraction decimal.Decimal `json:"exposure_fraction"`
+}
+
 type CreateReferalLink struct {
 	Name string `json:"name"`
 }
@@ -29,24 +48,70 @@ type WSrequest struct {
 }
 
 type Bet struct {
-	Amount   decimal.Decimal `json:"amount"`
-	NumGames uint64          `json:"num_games"`
+	Amount    decimal.Decimal `json:"amount"`
+	NumGames  uint64          `json:"num_games"`
+	UUID      string          `json:"-"`
+	RequestID uint            `json:"-"`
+	Data      string          `json:"data"`
+	GameID    uint            `json:"game_id"`
+	UserID    uint            `json:"-"`
+	CoinID    uint            `json:"coin_id"`
+	StopLoss  decimal.Decimal `json:"stop_loss"`
+	StopWin   decimal.Decimal `json:"stop_win"`
+	// IdempotencyKey makes a resent bet return the original result instead of settling again
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+type ContinueGame struct {
+	UUID      string `json:"-"`
+	RequestID uint   `json:"-"`
+	Data      string `json:"data"`
+	GameID    uint   `json:"game_id"`
+	UserID    uint   `json:"-"`
+	CoinID    uint   `json:"coin_id"`
+	// IdempotencyKey makes a resent step return the original result instead of playing again
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+// AutoBetStrategy decides the stake and the data of every auto bet round.
+// Percentages are added to the current stake, zero values disable a rule.
+type AutoBetStrategy struct {
+	OnLossIncrease decimal.Decimal `json:"on_loss_increase"`
+	OnWinIncrease  decimal.Decimal `json:"on_win_increase"`
+	ResetOnWin     bool            `json:"reset_on_win"`
+	// LossStreakData replaces the bet data after LossStreak losses in a row, e.g. a new Dice target
+	LossStreak     uint64 `json:"loss_streak"`
+	LossStreakData string `json:"loss_streak_data"`
+	// the session stops once the balance leaves these bounds
+	StopBalanceBelow decimal.Decimal `json:"stop_balance_below"`
+	StopBalanceAbove decimal.Decimal `json:"stop_balance_above"`
+	MaxRounds        uint64          `json:"max_rounds"`
+}
+
+type StartAutoBet struct {
 	UUID     string          `json:"-"`
-	Data     string          `json:"data"`
-	GameID   uint            `json:"game_id"`
 	UserID   uint            `json:"-"`
+	GameID   uint            `json:"game_id"`
 	CoinID   uint            `json:"coin_id"`
-	StopLoss decimal.Decimal `json:"stop_loss"`
-	StopWin  decimal.Decimal `json:"stop_win"`
+	Amount   decimal.Decimal `json:"amount"`
+	Data     string          `json:"data"`
+	Strategy AutoBetStrategy `json:"strategy"`
 }
 
-type ContinueGame struct {
-	UUID   string `json:"-"`
-	Data   string `json:"data"`
-	GameID uint   `json:"game_id"`
-	UserID uint   `json:"-"`
-	CoinID uint   `json:"coin_id"`
+type StopAutoBet struct {
+	SessionID uint `json:"session_id"`
 }
+
+// CrashBet joins the Crash round that is taking bets.
+type CrashBet struct {
+	UUID   string          `json:"-"`
+	UserID uint            `json:"-"`
+	CoinID uint            `json:"coin_id"`
+	Amount decimal.Decimal `json:"amount"`
+	// AutoCashout cashes the bet out once the multiplier reaches it, zero disables it
+	AutoCashout decimal.Decimal `json:"auto_cashout"`
+}
+
 type GetState struct {
 	GameID uint `json:"game_id"`
 	CoinID uint `json:"coin_id"`
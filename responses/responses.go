@@ -58,6 +58,8 @@ type Bet struct {
 	BetInfo      string          `json:"bet_info"`
 	State        string          `json:"state"`
 	UUID         string          `json:"uuid"`
+	Nonce        uint64          `json:"nonce"`
+	Steps        string          `json:"steps"`
 	GameID       uint            `json:"game_id"`
 	UserID       uint            `json:"user_id"`
 	Username     string          `json:"username"`
@@ -66,6 +68,95 @@ type Bet struct {
 	ServerSeedID uint            `json:"server_seed_id"`
 }
 
+type BetErrorCode string
+
+const (
+	TooManyGames        BetErrorCode = "too_many_games"
+	StakeTooHigh        BetErrorCode = "stake_too_high"
+	StakeTooLow         BetErrorCode = "stake_too_low"
+	InsufficientBalance BetErrorCode = "insufficient_balance"
+	ExposureExceeded    BetErrorCode = "exposure_exceeded"
+	UnknownGame         BetErrorCode = "unknown_game"
+	UnknownCoin         BetErrorCode = "unknown_coin"
+	BadBetData          BetErrorCode = "bad_bet_data"
+	NoSeed              BetErrorCode = "no_seed"
+	NoGameState         BetErrorCode = "no_game_state"
+	GameInProgress      BetErrorCode = "game_in_progress"
+	DuplicateBet        BetErrorCode = "duplicate_bet"
+	ShuttingDown        BetErrorCode = "shutting_down"
+	BetVetoed           BetErrorCode = "bet_vetoed"
+	InternalError       BetErrorCode = "internal_error"
+	RoundClosed         BetErrorCode = "round_closed"
+)
+
+// BetReply is sent to the websocket that placed a bet once the engine has handled it.
+type BetReply struct {
+	Accepted bool         `json:"accepted"`
+	Code     BetErrorCode `json:"code,omitempty"`
+	Message  string       `json:"message,omitempty"`
+	// Replayed is set when the idempotency key was used before,
+	// Result then holds the bet or the game state created the first time.
+	Replayed bool        `json:"replayed,omitempty"`
+	Result   interface{} `json:"result,omitempty"`
+}
+
+// AutoBetUpdate is sent to the websocket of an auto bet session after every round
+// and when the session stops.
+type AutoBetUpdate struct {
+	SessionID uint `json:"session_id"`
+	// BetID is the bet of the round, zero when the session stopped without playing
+	BetID      uint            `json:"bet_id,omitempty"`
+	Rounds     uint64          `json:"rounds"`
+	Amount     decimal.Decimal `json:"amount"`
+	Data       string          `json:"data"`
+	Profit     decimal.Decimal `json:"profit"`
+	Active     bool            `json:"active"`
+	StopReason string          `json:"stop_reason,omitempty"`
+}
+
+type CrashPhase string
+
+const (
+	CrashBetting   CrashPhase = "betting"
+	CrashRunning   CrashPhase = "running"
+	CrashCrashed   CrashPhase = "crashed"
+	CrashCancelled CrashPhase = "cancelled"
+)
+
+// CrashUpdate is broadcast to the subscribers of the Crash game whenever the round changes.
+// Hash and CrashPoint are only sent once the round crashed.
+type CrashUpdate struct {
+	RoundID       uint             `json:"round_id"`
+	Phase         CrashPhase       `json:"phase"`
+	BettingEndsAt *time.Time       `json:"betting_ends_at,omitempty"`
+	Multiplier    decimal.Decimal  `json:"multiplier"`
+	CrashPoint    *decimal.Decimal `json:"crash_point,omitempty"`
+	Hash          string           `json:"hash,omitempty"`
+	Cashouts      []CrashCashout   `json:"cashouts,omitempty"`
+}
+
+type CrashCashout struct {
+	UserID     uint            `json:"user_id"`
+	Multiplier decimal.Decimal `json:"multiplier"`
+	Payout     decimal.Decimal `json:"payout"`
+}
+
+type BetVerification struct {
+	BetID         uint              `json:"bet_id"`
+	Verified      bool              `json:"verified"`
+	OutcomesMatch bool              `json:"outcomes_match"`
+	ProfitsMatch  bool              `json:"profits_match"`
+	Outcomes      []uint64          `json:"outcomes"`
+	Profits       []decimal.Decimal `json:"profits"`
+	TotalProfit   decimal.Decimal   `json:"total_profit"`
+}
+
+type EngineQueue struct {
+	Worker   int `json:"worker"`
+	Queued   int `json:"queued"`
+	Capacity int `json:"capacity"`
+}
+
 type Leaderboard struct {
 	UserId   uint            `gorm:"user_id" json:"user_id"`
 	Total    decimal.Decimal `gorm:"total" json:"total"`